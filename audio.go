@@ -11,136 +11,293 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
 	"os"
-	"runtime"
 	"sync"
+	"time"
+
+	"github.com/nixpare/AudioSwitch/hresult"
 )
 
 type SaveState struct {
-	Prefs    map[string]*Device
-	Selected string
-	Muted    bool
+	Prefs         map[string]*Device
+	Selected      string
+	SelectionMode SelectionMode
+	Muted         bool
+	MuteLock      bool
+	SpeechDetect  SpeechDetectConfig
+	VOX           VOXConfig
+	Feedback      FeedbackConfig
 }
 
 type State struct {
 	SaveState
 
-	Devices map[string]*Device
+	Devices   map[string]*Device
+	Defaults  DefaultDevices
+	Volume    float32
+	Origin    ChangeOrigin
+	Reconnect *ReconnectState
 }
 
+// ChangeOrigin tells who caused the last state change: the app itself,
+// another application or someone that did not provide an event context
+type ChangeOrigin string
+
+const (
+	OriginSelf     ChangeOrigin = "self"
+	OriginExternal ChangeOrigin = "external"
+	OriginUnknown  ChangeOrigin = "unknown"
+)
+
 type AudioService struct {
 	State
 
+	com         *comThread
+	events      *eventQueue
+	handle      C.UINT_PTR
 	deviceEnum  *C.IMMDeviceEnumerator
 	notifClient *C.IMMNotificationClient
+	history     *History
+	policy      PolicyConfig
+	player      Player
+	publisher   Publisher
+	savePath    string
+	historyPath string
+
+	levelSubscribers         int
+	selectedLevelSubscribers int
+	meterStop                chan struct{}
+	speech                   *speechDetector
+	vox                      *voxMachine
 
 	running bool
 	m       sync.Mutex
 }
 
+type MuteBlockedEvent struct {
+	DeviceID string
+	Muted    bool
+	Origin   ChangeOrigin
+}
+
 var (
 	ErrAudioServiceNotRunning = errors.New("audio service not running")
-	ErrDeviceNotFound         = errors.New("device not found")
+	ErrDeviceNotFound         = hresult.ErrDeviceNotFound
 )
 
-func initCOMLibraryMultithreaded() error {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
+// eventContext is generated once per process and passed to every SetMute
+// and SetVolume call made by the app, so that the resulting notifications
+// can be told apart from the ones caused by other applications
+var eventContext C.GUID
 
-	var wg sync.WaitGroup
-	var err error
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		hr := C.CoInitializeEx(nil, C.COINIT_MULTITHREADED)
-		if int32(hr) < 0 {
-			err = fmt.Errorf("COM library init failed with code 0x%x", uint32(hr))
-		}
-	}()
-
-	wg.Wait()
-	return err
+func eventOrigin(context C.GUID) ChangeOrigin {
+	switch context {
+	case eventContext:
+		return OriginSelf
+	case C.GUID{}:
+		return OriginUnknown
+	default:
+		return OriginExternal
+	}
 }
 
-func newAudioService() *AudioService {
+// newAudioService creates a stopped service that emits its events to publisher
+// and keeps its state in savePath and its history in historyPath
+func newAudioService(publisher Publisher, savePath string, historyPath string) *AudioService {
 	return &AudioService{
 		State: State{
 			Devices: make(map[string]*Device),
 		},
+		policy:      windowsPolicyConfig{},
+		publisher:   publisher,
+		savePath:    savePath,
+		historyPath: historyPath,
 	}
 }
 
 func (s *AudioService) Start() error {
+	events, err := s.start()
+	if events != nil {
+		// Same as Stop, the event processing may be waiting for
+		// the lock, so it must be stopped after releasing it
+		events.close()
+	}
+
+	return err
+}
+
+// start initializes the service, when it fails after creating the
+// event queue the queue is returned so that the caller can close it
+func (s *AudioService) start() (*eventQueue, error) {
 	s.m.Lock()
 	defer s.m.Unlock()
 
 	if s.running {
-		return nil
+		return nil, nil
 	}
 
 	err := s.loadSaveData()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = initCOMLibraryMultithreaded()
+	s.history, err = openHistory(s.historyPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	s.com, err = startCOMThread()
+	if err != nil {
+		s.history.Close()
+		return nil, err
+	}
+
+	// The queue must exist before the callbacks are registered, its
+	// processing waits for the lock held until the service is running
+	s.events = newEventQueue()
+	s.handle = registerNotificationHandle(s.events)
+	go s.processEvents(s.events)
+
+	err = s.com.do(s.startCOM)
+	if err != nil {
+		s.com.do(s.stopCOM)
+		s.com.stop()
+		s.history.Close()
+		unregisterNotificationHandle(s.handle)
+
+		return s.events, err
+	}
+
+	// The cues are optional, the service can work without them
+	player, err := newTonePlayer()
+	if err != nil {
+		slog.Warn("feedback player unavailable", "err", err)
+	} else {
+		s.player = player
+	}
+
+	s.running = true
+	s.updateMetering()
+	return nil, nil
+}
+
+// startCOM creates every COM object needed by the service,
+// it must run on the COM thread
+func (s *AudioService) startCOM() error {
+	if eventContext == (C.GUID{}) {
+		if hr := C.CoCreateGuid(&eventContext); hr < 0 {
+			return fmt.Errorf("event context create: %w", HRESULT(hr))
+		}
 	}
 
 	// Create IMMDeviceEnumerator instance
 	hr := C.CreateInstance(&s.deviceEnum)
 	if hr < 0 {
-		return fmt.Errorf("device enumerator create: 0x%x", uint32(hr))
+		return fmt.Errorf("device enumerator create: %w", HRESULT(hr))
 	}
 
-	err = s.updateDeviceList()
+	err := s.updateDeviceList()
 	if err != nil {
 		return fmt.Errorf("device list update: %w", err)
 	}
 
-	// Start listening for device events
-	err = s.listenForDeviceEvents()
+	err = s.loadDefaults()
 	if err != nil {
 		return err
 	}
 
-	s.running = true
-	return nil
+	if s.SelectionMode == SelectionFollowDefault {
+		err = s.followDefault()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start listening for device events
+	return s.listenForDeviceEvents()
 }
 
 func (s *AudioService) Stop() error {
+	events, err := s.stop()
+	if events != nil {
+		// The event processing may be waiting for the lock,
+		// so it must be stopped after releasing it
+		events.close()
+	}
+
+	return err
+}
+
+// stop releases everything but the event queue, which is returned
+// when the service has been stopped
+func (s *AudioService) stop() (*eventQueue, error) {
 	s.m.Lock()
 	defer s.m.Unlock()
 
 	var errs []error
 
 	if !s.running {
-		return nil
+		return nil, nil
 	}
 
 	if err := s.updateSaveData(); err != nil {
-		return err
+		return nil, err
+	}
+
+	s.levelSubscribers = 0
+	s.selectedLevelSubscribers = 0
+	s.stopMetering()
+
+	if err := s.com.do(s.stopCOM); err != nil {
+		errs = append(errs, err)
+	}
+	s.com.stop()
+
+	// Any late callback is dropped from now on
+	unregisterNotificationHandle(s.handle)
+
+	if err := s.history.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("history close: %w", err))
+	}
+
+	if s.player != nil {
+		if err := s.player.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("feedback player close: %w", err))
+		}
+		s.player = nil
 	}
 
+	s.running = false
+	return s.events, errors.Join(errs...)
+}
+
+// stopCOM releases every COM object owned by the service,
+// it must run on the COM thread
+func (s *AudioService) stopCOM() error {
+	var err error
+
 	for _, device := range s.Devices {
 		device.release()
 	}
 	clear(s.Devices)
 
 	// Unregister IMMNotificationClient callbacks
-	if hr := C.UnregisterNotificationClient(s.deviceEnum, s.notifClient); hr < 0 {
-		errs = append(errs, fmt.Errorf("device enumerator create: 0x%x", uint32(hr)))
+	if s.notifClient != nil {
+		if hr := C.UnregisterNotificationClient(s.deviceEnum, s.notifClient); hr < 0 {
+			err = fmt.Errorf("audio notification unregistration: %w", HRESULT(hr))
+		}
+		s.notifClient = nil
 	}
+
 	// Release IMMDeviceEnumerator instance
-	C.IMMDeviceEnumerator_Release(s.deviceEnum)
-	C.CoUninitialize()
+	if s.deviceEnum != nil {
+		C.IMMDeviceEnumerator_Release(s.deviceEnum)
+		s.deviceEnum = nil
+	}
 
-	s.running = false
-	return errors.Join(errs...)
+	return err
 }
 
 func (s *AudioService) GetState() (State, error) {
@@ -151,11 +308,44 @@ func (s *AudioService) GetState() (State, error) {
 		return State{}, ErrAudioServiceNotRunning
 	}
 
-	return s.State, nil
+	return s.State.clone(), nil
+}
+
+// clone returns a copy of the state that shares nothing with the service,
+// so it can be read after the lock is released. The devices keep only
+// their DeviceState
+func (st State) clone() State {
+	st.Prefs = cloneDevices(st.Prefs)
+	st.Devices = cloneDevices(st.Devices)
+
+	defaults := make(DefaultDevices, len(st.Defaults))
+	for flow, roles := range st.Defaults {
+		defaults[flow] = maps.Clone(roles)
+	}
+	st.Defaults = defaults
+
+	if st.Reconnect != nil {
+		reconnect := *st.Reconnect
+		st.Reconnect = &reconnect
+	}
+
+	return st
+}
+
+func cloneDevices(devices map[string]*Device) map[string]*Device {
+	if devices == nil {
+		return nil
+	}
+
+	clone := make(map[string]*Device, len(devices))
+	for id, device := range devices {
+		clone[id] = &Device{DeviceState: device.DeviceState}
+	}
+	return clone
 }
 
 func (s *AudioService) updateDeviceList() error {
-	devices, err := s.getDeviceCollection()
+	devices, err := s.getDeviceCollection(C.eCapture, C.DEVICE_STATEMASK_ALL)
 	if err != nil {
 		return err
 	}
@@ -174,23 +364,105 @@ func (s *AudioService) updateDeviceList() error {
 			device.release()
 		} else {
 			s.Devices[device.ID] = device
+			s.linkPref(device)
+			if s.running {
+				s.record(HistoryEvent{Kind: HistoryDeviceAdded, DeviceID: device.ID, Detail: device.Name})
+			}
 		}
 	}
 
 	for id, found := range check {
 		if !found {
-			s.Devices[id].release()
-			delete(s.Devices, id)
+			s.removeDevice(id)
 		}
 	}
 
-	if device, ok := s.Devices[s.Selected]; ok {
-		err = device.activate()
-		if err != nil {
-			return err
-		}
+	err = s.rebindPrefs()
+	if err != nil {
+		return err
 	}
 
+	return s.activateSelected()
+}
+
+// activateSelected activates the selected device, or deactivates
+// it if it is not usable anymore
+func (s *AudioService) activateSelected() error {
+	device, ok := s.Devices[s.Selected]
+	if !ok {
+		return nil
+	}
+
+	if !device.isActive() {
+		device.deactivate()
+		return nil
+	}
+
+	return s.withRecovery(device, device.activate)
+}
+
+// linkPref makes the preference of the device, if any, follow its state
+func (s *AudioService) linkPref(device *Device) {
+	if _, ok := s.Prefs[device.ID]; ok {
+		s.Prefs[device.ID] = device
+	}
+}
+
+// addDevice adds the device with the given id to the list, returning
+// false if it is already known or if it is not a recording device
+func (s *AudioService) addDevice(id string) (bool, error) {
+	if _, ok := s.Devices[id]; ok {
+		return false, nil
+	}
+
+	device, err := openDevice(s, s.deviceEnum, id)
+	if err != nil {
+		return false, err
+	}
+
+	capture, err := device.isCapture()
+	if err != nil || !capture {
+		device.release()
+		return false, err
+	}
+
+	s.Devices[id] = device
+	s.linkPref(device)
+	s.record(HistoryEvent{Kind: HistoryDeviceAdded, DeviceID: id, Detail: device.Name})
+
+	if id == s.Selected {
+		return true, s.activateSelected()
+	}
+
+	return true, nil
+}
+
+// removeDevice removes the device with the given id from the list,
+// returning false if it was not known
+func (s *AudioService) removeDevice(id string) bool {
+	device, ok := s.Devices[id]
+	if !ok {
+		return false
+	}
+
+	s.record(HistoryEvent{Kind: HistoryDeviceRemoved, DeviceID: id, Detail: device.Name})
+	device.release()
+	delete(s.Devices, id)
+
+	// The preference, if any, still points to the released device
+	device.State = DeviceNotPresent
+	return true
+}
+
+// updateDeviceState sets the state of a known device, activating or
+// deactivating it if it is the selected one
+func (s *AudioService) updateDeviceState(id string, state string) error {
+	device := s.Devices[id]
+	device.State = state
+
+	if id == s.Selected {
+		return s.activateSelected()
+	}
 	return nil
 }
 
@@ -202,13 +474,19 @@ func (s *AudioService) SetDevice(id string) error {
 		return ErrAudioServiceNotRunning
 	}
 
+	return s.com.do(func() error {
+		return s.setDevice(id)
+	})
+}
+
+func (s *AudioService) setDevice(id string) error {
 	for deviceID, device := range s.Devices {
 		if deviceID != id {
 			continue
 		}
 
 		if id == s.Selected {
-			return s.updateFrontend(false)
+			return s.updateFrontend(false, OriginSelf)
 		}
 
 		// TODO: handle error
@@ -217,21 +495,26 @@ func (s *AudioService) SetDevice(id string) error {
 		}
 
 		s.Selected = id
-		err := device.activate()
+		s.Reconnect = nil
+		s.record(HistoryEvent{Kind: HistorySelectionChanged, DeviceID: id, Origin: OriginSelf, Detail: device.Name})
+
+		// An inactive device is selected like a missing preference:
+		// it will be activated as soon as it becomes available
+		err := s.activateSelected()
 		if err != nil {
 			return err
 		}
 
-		return s.updateFrontend(false)
+		return s.updateFrontend(false, OriginSelf)
 	}
 
-	for deviceID := range s.Prefs {
+	for deviceID, device := range s.Prefs {
 		if deviceID != id {
 			continue
 		}
 
 		if id == s.Selected {
-			return s.updateFrontend(false)
+			return s.updateFrontend(false, OriginSelf)
 		}
 
 		// TODO: handle error
@@ -240,7 +523,10 @@ func (s *AudioService) SetDevice(id string) error {
 		}
 
 		s.Selected = id
-		return s.updateFrontend(false)
+		s.Reconnect = nil
+		s.record(HistoryEvent{Kind: HistorySelectionChanged, DeviceID: id, Origin: OriginSelf, Detail: device.Name})
+
+		return s.updateFrontend(false, OriginSelf)
 	}
 
 	return ErrDeviceNotFound
@@ -257,7 +543,7 @@ func (s *AudioService) TogglePref(id string) error {
 	_, ok := s.Prefs[id]
 	if ok {
 		delete(s.Prefs, id)
-		return s.updateFrontend(false)
+		return s.updateFrontend(false, OriginSelf)
 	}
 
 	device, ok := s.Devices[id]
@@ -266,7 +552,7 @@ func (s *AudioService) TogglePref(id string) error {
 	}
 
 	s.Prefs[id] = device
-	return s.updateFrontend(false)
+	return s.updateFrontend(false, OriginSelf)
 }
 
 func (s *AudioService) ToggleSelected() error {
@@ -277,13 +563,161 @@ func (s *AudioService) ToggleSelected() error {
 		return ErrAudioServiceNotRunning
 	}
 
-	return s.setPrefMute(!s.Muted)
+	return s.toggleWithCue()
+}
+
+// hotkeyToggle is ToggleSelected for the global hotkey, the key press is
+// recorded in the history too
+func (s *AudioService) hotkeyToggle(hotkey string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	s.record(HistoryEvent{Kind: HistoryHotkeyPressed, DeviceID: s.Selected, Detail: hotkey})
+	return s.toggleWithCue()
+}
+
+// toggleWithCue toggles the selected device and plays the matching cue,
+// it must be called while holding the lock
+func (s *AudioService) toggleWithCue() error {
+	wasMuted := s.Muted
+	err := s.com.do(s.toggleSelected)
+	s.playToggleCue(wasMuted, err)
+
+	if err != nil {
+		// The callers log it without reading Selected outside of the lock
+		return fmt.Errorf("device %s toggle: %w", s.Selected, err)
+	}
+	return nil
+}
+
+func (s *AudioService) toggleSelected() error {
+	muted := !s.Muted
+	err := s.setPrefMute(muted)
+	if err != nil {
+		return err
+	}
+
+	// The selected preference is not connected, nothing changed
+	if s.Muted != muted {
+		return nil
+	}
+
+	s.record(HistoryEvent{Kind: HistoryMuteChanged, DeviceID: s.Selected, Origin: OriginSelf, Muted: &muted})
+	return s.updateFrontend(false, OriginSelf)
+}
+
+func (s *AudioService) SetVolume(volume float32) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	return s.com.do(func() error {
+		return s.setVolume(volume)
+	})
+}
+
+func (s *AudioService) setVolume(volume float32) error {
+	device, ok := s.Devices[s.Selected]
+	if !ok {
+		return ErrDeviceNotFound
+	}
+
+	volume = min(max(volume, 0), 1)
+	err := s.withRecovery(device, func() error {
+		return device.setVolume(volume)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Volume = volume
+	s.record(HistoryEvent{Kind: HistoryVolumeChanged, DeviceID: s.Selected, Origin: OriginSelf, Volume: &volume})
+
+	return s.updateFrontend(false, OriginSelf)
+}
+
+func (s *AudioService) SetMuteLock(enabled bool) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	s.MuteLock = enabled
+	if enabled {
+		slog.Info("mute lock engaged", "device", s.Selected, "muted", s.Muted)
+	} else {
+		slog.Info("mute lock released", "device", s.Selected)
+	}
+
+	return s.updateFrontend(false, OriginSelf)
+}
+
+// enforceMuteLock reverts a mute change made by another application if
+// the mute lock is engaged, reporting whether it did so
+func (s *AudioService) enforceMuteLock(muted bool, origin ChangeOrigin) (bool, error) {
+	if !s.MuteLock || muted == s.Muted {
+		return false, nil
+	}
+
+	muted = s.Muted
+	err := s.setPrefMute(muted)
+	if err != nil {
+		return true, fmt.Errorf("mute lock enforce: %w", err)
+	}
+
+	s.record(HistoryEvent{
+		Kind:     HistoryMuteChanged,
+		DeviceID: s.Selected,
+		Origin:   OriginSelf,
+		Muted:    &muted,
+		Detail:   fmt.Sprintf("mute lock reverted a change from %s origin", origin),
+	})
+
+	slog.Warn("mute lock blocked an external change", "device", s.Selected, "muted", muted, "origin", origin)
+
+	s.publisher.Emit("audio-mute-blocked", MuteBlockedEvent{
+		DeviceID: s.Selected,
+		Muted:    muted,
+		Origin:   origin,
+	})
+
+	return true, nil
+}
+
+// mirrorVolumeChange applies to the state a mute or volume change that was
+// not made by the app itself
+func (s *AudioService) mirrorVolumeChange(muted bool, volume float32, origin ChangeOrigin) error {
+	if muted == s.Muted && volume == s.Volume {
+		return nil
+	}
+
+	if muted != s.Muted {
+		s.record(HistoryEvent{Kind: HistoryMuteChanged, DeviceID: s.Selected, Origin: origin, Muted: &muted})
+	}
+	if volume != s.Volume {
+		s.record(HistoryEvent{Kind: HistoryVolumeChanged, DeviceID: s.Selected, Origin: origin, Volume: &volume})
+	}
+
+	s.Muted = muted
+	s.Volume = volume
+	return s.updateFrontend(false, origin)
 }
 
 func (s *AudioService) setPrefMute(muted bool) error {
 	device, ok := s.Devices[s.Selected]
 	if ok {
-		err := device.setMuted(muted)
+		err := s.withRecovery(device, func() error {
+			return device.setMuted(muted)
+		})
 		if err != nil {
 			return err
 		}
@@ -300,8 +734,53 @@ func (s *AudioService) setPrefMute(muted bool) error {
 	return ErrDeviceNotFound
 }
 
+func (s *AudioService) GetHistory(since time.Time, limit int) ([]HistoryEvent, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return nil, ErrAudioServiceNotRunning
+	}
+
+	return s.history.Read(since, limit)
+}
+
+func (s *AudioService) ExportHistory(path string, format string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0660)
+	if err != nil {
+		return fmt.Errorf("history export file open: %w", err)
+	}
+	defer f.Close()
+
+	err = s.history.Export(f, format, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("history exported", "path", path, "format", format)
+	return nil
+}
+
+func (s *AudioService) record(ev HistoryEvent) {
+	if s.history == nil {
+		return
+	}
+
+	err := s.history.Append(ev)
+	if err != nil {
+		slog.Error("history record failed", "op", "record", "kind", ev.Kind, "device", ev.DeviceID, "err", err)
+	}
+}
+
 func (s *AudioService) loadSaveData() error {
-	saveFile, err := os.OpenFile(audioSaveFilePath, os.O_RDONLY|os.O_CREATE, 0660)
+	saveFile, err := os.OpenFile(s.savePath, os.O_RDONLY|os.O_CREATE, 0660)
 	if err != nil {
 		return fmt.Errorf("save file open: %w", err)
 	}
@@ -314,6 +793,12 @@ func (s *AudioService) loadSaveData() error {
 
 	if len(saveData) == 0 {
 		s.State.SaveState.Prefs = make(map[string]*Device)
+		s.State.SaveState.SelectionMode = SelectionManual
+		s.State.SaveState.SpeechDetect = defaultSpeechDetectConfig
+		s.State.SaveState.VOX = defaultVOXConfig
+		s.State.SaveState.Feedback = defaultFeedbackConfig
+		s.speech = newSpeechDetector(s.SpeechDetect)
+		s.vox = newVOXMachine(s.VOX)
 		return nil
 	}
 
@@ -322,6 +807,22 @@ func (s *AudioService) loadSaveData() error {
 		return fmt.Errorf("save data decode: %w", err)
 	}
 
+	if s.SelectionMode == "" {
+		s.SelectionMode = SelectionManual
+	}
+	if s.SpeechDetect.validate() != nil {
+		s.SpeechDetect = defaultSpeechDetectConfig
+	}
+	s.speech = newSpeechDetector(s.SpeechDetect)
+
+	if s.VOX.validate() != nil {
+		s.VOX = defaultVOXConfig
+	}
+	if s.Feedback.validate() != nil {
+		s.Feedback = defaultFeedbackConfig
+	}
+	s.vox = newVOXMachine(s.VOX)
+
 	return nil
 }
 
@@ -331,7 +832,7 @@ func (s *AudioService) updateSaveData() error {
 		return fmt.Errorf("save data encode: %w", err)
 	}
 
-	saveFile, err := os.OpenFile(audioSaveFilePath, os.O_WRONLY|os.O_TRUNC, 0)
+	saveFile, err := os.OpenFile(s.savePath, os.O_WRONLY|os.O_TRUNC, 0)
 	if err != nil {
 		return fmt.Errorf("save file open: %w", err)
 	}
@@ -345,38 +846,53 @@ func (s *AudioService) updateSaveData() error {
 	return nil
 }
 
+// deviceActivated is called by a device when it becomes usable, the
+// endpoint state of the selected one is the one shown by the service
+func (s *AudioService) deviceActivated(d *Device, muted bool, volume float32) {
+	if d.ID != s.Selected {
+		return
+	}
+	s.Muted, s.Volume = muted, volume
+}
+
+func (s *AudioService) notificationHandle() C.UINT_PTR {
+	return s.handle
+}
+
 func (s *AudioService) listenForDeviceEvents() error {
-	if hr := C.RegisterNotificationClient(s.deviceEnum, &s.notifClient); hr < 0 {
-		return fmt.Errorf("audio notification registration: 0x%x", uint32(hr))
+	if hr := C.RegisterNotificationClient(s.deviceEnum, s.handle, &s.notifClient); hr < 0 {
+		return fmt.Errorf("audio notification registration: %w", HRESULT(hr))
 	}
 	return nil
 }
 
-func (s *AudioService) updateFrontend(regenerateList bool) error {
+func (s *AudioService) updateFrontend(regenerateList bool, origin ChangeOrigin) error {
 	if regenerateList {
 		err := s.updateDeviceList()
 		if err != nil {
-			log.Printf("audio service: device list update error: %v\n", err)
+			slog.Error("device list update failed", "op", "updateDeviceList", "err", err)
 			return err
 		}
 	}
 
-	app.EmitEvent("audio-device-update", s.State)
+	s.Origin = origin
+	s.updateMetering()
+	s.publisher.Emit("audio-device-update", s.State.clone())
 	return nil
 }
 
-func (s *AudioService) getDeviceCollection() ([]*Device, error) {
+func (s *AudioService) getDeviceCollection(flow C.EDataFlow, stateMask C.DWORD) ([]*Device, error) {
 	// Enumerate audio endpoints (eRender for playback devices, eCapture for recording devices)
 	var deviceCollection *C.IMMDeviceCollection
-	if hr := C.IMMDeviceEnumerator_EnumAudioEndpoints(s.deviceEnum, &deviceCollection); hr < 0 {
-		return nil, fmt.Errorf("audio device collection: 0x%x", uint32(hr))
+	if hr := C.IMMDeviceEnumerator_EnumAudioEndpoints(s.deviceEnum, flow, stateMask, &deviceCollection); hr < 0 {
+		return nil, fmt.Errorf("audio device collection: %w", HRESULT(hr))
 	}
 	defer C.IMMDeviceCollection_Release(deviceCollection)
 
 	// Get the number of audio devices
 	var count C.uint
 	if hr := C.IMMDeviceCollection_GetCount(deviceCollection, &count); hr < 0 {
-		return nil, fmt.Errorf("audio device collection count: 0x%x", uint32(hr))
+		return nil, fmt.Errorf("audio device collection count: %w", HRESULT(hr))
 	}
 
 	devices := make([]*Device, 0, count)
@@ -385,13 +901,17 @@ func (s *AudioService) getDeviceCollection() ([]*Device, error) {
 	for i := range count {
 		var immDevice *C.IMMDevice
 		if hr := C.IMMDeviceCollection_Item(deviceCollection, i, &immDevice); hr < 0 {
-			log.Printf("audio service: device collection item error: 0x%x\n", hr)
+			slog.Error("device collection item failed", "op", "IMMDeviceCollection_Item", "index", uint(i), "hresult", HRESULT(hr))
 			continue
 		}
 
-		device, err := newDevice(immDevice)
+		device, err := newDevice(s, immDevice)
+		if errors.Is(err, ErrDeviceGone) {
+			slog.Warn("device removed while enumerating", "op", "newDevice", "err", err)
+			continue
+		}
 		if err != nil {
-			log.Printf("audio service: device error: %v\n", err)
+			slog.Error("device init failed", "op", "newDevice", "err", err)
 			continue
 		}
 
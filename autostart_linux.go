@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const autostartDesktopFile = "audioswitch.desktop"
+
+// autostartEnabled reports whether the XDG autostart entry launches this executable
+func autostartEnabled() (bool, error) {
+	path, err := autostartPath()
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("autostart entry read: %w", err)
+	}
+
+	entry, err := autostartEntry()
+	if err != nil {
+		return false, err
+	}
+	return string(data) == entry, nil
+}
+
+func setAutostart(enabled bool) error {
+	path, err := autostartPath()
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("autostart entry delete: %w", err)
+		}
+		return nil
+	}
+
+	entry, err := autostartEntry()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("autostart directory: %w", err)
+	}
+
+	err = os.WriteFile(path, []byte(entry), 0644)
+	if err != nil {
+		return fmt.Errorf("autostart entry write: %w", err)
+	}
+	return nil
+}
+
+// autostartPath follows XDG_CONFIG_HOME, defaulting to ~/.config
+func autostartPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("autostart directory: %w", err)
+	}
+	return filepath.Join(dir, "autostart", autostartDesktopFile), nil
+}
+
+func autostartEntry() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("autostart executable: %w", err)
+	}
+
+	// Exec arguments containing spaces or quotes must be quoted
+	exe = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(exe) + `"`
+
+	return strings.Join([]string{
+		"[Desktop Entry]",
+		"Type=Application",
+		"Name=AudioSwitch",
+		"Comment=A switch for toggling audio devices",
+		"Exec=" + exe,
+		"X-GNOME-Autostart-enabled=true",
+		"",
+	}, "\n"), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	autostartRunKey    = `Software\Microsoft\Windows\CurrentVersion\Run`
+	autostartValueName = "AudioSwitch"
+)
+
+// autostartEnabled reports whether the Run key launches this executable
+func autostartEnabled() (bool, error) {
+	k, err := registry.OpenKey(registry.CURRENT_USER, autostartRunKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false, fmt.Errorf("autostart key open: %w", err)
+	}
+	defer k.Close()
+
+	value, _, err := k.GetStringValue(autostartValueName)
+	if errors.Is(err, registry.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("autostart value read: %w", err)
+	}
+
+	cmd, err := autostartCommand()
+	if err != nil {
+		return false, err
+	}
+	return value == cmd, nil
+}
+
+func setAutostart(enabled bool) error {
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, autostartRunKey, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("autostart key open: %w", err)
+	}
+	defer k.Close()
+
+	if !enabled {
+		err = k.DeleteValue(autostartValueName)
+		if err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return fmt.Errorf("autostart value delete: %w", err)
+		}
+		return nil
+	}
+
+	cmd, err := autostartCommand()
+	if err != nil {
+		return err
+	}
+
+	err = k.SetStringValue(autostartValueName, cmd)
+	if err != nil {
+		return fmt.Errorf("autostart value write: %w", err)
+	}
+	return nil
+}
+
+func autostartCommand() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("autostart executable: %w", err)
+	}
+	return `"` + exe + `"`, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+/*
+#include "winaudio_wrapper.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+var ErrCOMThreadStopped = errors.New("COM thread stopped")
+
+// comThread owns a locked OS thread, initialised for COM, and runs on it
+// every Core Audio call queued with do. This way no COM object is ever used
+// by a thread that did not initialise the library, and the objects can be
+// released synchronously and in order when the service stops
+type comThread struct {
+	reqs chan func()
+	done chan struct{}
+}
+
+func startCOMThread() (*comThread, error) {
+	t := &comThread{
+		reqs: make(chan func()),
+		done: make(chan struct{}),
+	}
+
+	ready := make(chan error)
+	go t.run(ready)
+
+	err := <-ready
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *comThread) run(ready chan<- error) {
+	defer close(t.done)
+
+	// The thread is never unlocked, so that it is terminated
+	// together with this goroutine
+	runtime.LockOSThread()
+
+	hr := C.CoInitializeEx(nil, C.COINIT_MULTITHREADED)
+	if hr < 0 {
+		ready <- fmt.Errorf("COM library init: %w", HRESULT(hr))
+		return
+	}
+	defer C.CoUninitialize()
+
+	ready <- nil
+
+	for fn := range t.reqs {
+		fn()
+	}
+}
+
+// do runs fn on the COM thread and waits for its result. It must never be
+// called from a function that is already running on the COM thread
+func (t *comThread) do(fn func() error) error {
+	errCh := make(chan error, 1)
+
+	select {
+	case t.reqs <- func() { errCh <- fn() }:
+	case <-t.done:
+		return ErrCOMThreadStopped
+	}
+
+	return <-errCh
+}
+
+// stop waits for the queued calls to complete, then uninitialises COM
+// and terminates the thread
+func (t *comThread) stop() {
+	close(t.reqs)
+	<-t.done
+}
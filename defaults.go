@@ -0,0 +1,200 @@
+package main
+
+/*
+#include "winaudio_wrapper.h"
+#include "policyconfig.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"syscall"
+	"unsafe"
+)
+
+// SelectionMode tells how the selected device is chosen
+type SelectionMode string
+
+const (
+	// SelectionManual keeps the device chosen by the user
+	SelectionManual SelectionMode = "manual"
+	// SelectionFollowDefault selects the default capture device of the
+	// system for the console role, following its changes
+	SelectionFollowDefault SelectionMode = "follow-default"
+)
+
+// DefaultDevices maps each data flow, then each role,
+// to the ID of its default device ("" when there is none)
+type DefaultDevices map[string]map[string]string
+
+var ErrSelectionMode = errors.New("unknown selection mode")
+
+var (
+	dataFlows = []C.EDataFlow{C.eRender, C.eCapture}
+	roles     = []C.ERole{C.eConsole, C.eMultimedia, C.eCommunications}
+)
+
+// PolicyConfig changes the system audio configuration. On Windows it
+// is implemented with IPolicyConfig, a PulseAudio implementation
+// would use set-default-source instead
+type PolicyConfig interface {
+	// SetDefaultEndpoint makes the device with the given id
+	// the default one for the role
+	SetDefaultEndpoint(id string, role string) error
+}
+
+type windowsPolicyConfig struct{}
+
+func (windowsPolicyConfig) SetDefaultEndpoint(id string, role string) error {
+	var cRole C.ERole
+	switch role {
+	case "console":
+		cRole = C.eConsole
+	case "multimedia":
+		cRole = C.eMultimedia
+	case "communications":
+		cRole = C.eCommunications
+	default:
+		return fmt.Errorf("unknown role %s", role)
+	}
+
+	wid, err := syscall.UTF16PtrFromString(id)
+	if err != nil {
+		return fmt.Errorf("device %s id: %w", id, err)
+	}
+
+	if hr := C.PolicyConfig_SetDefaultEndpoint((C.LPCWSTR)(unsafe.Pointer(wid)), cRole); hr < 0 {
+		return fmt.Errorf("device %s set default %s: %w", id, role, HRESULT(hr))
+	}
+	return nil
+}
+
+// loadDefaults reads the default device of every data flow and role
+func (s *AudioService) loadDefaults() error {
+	s.Defaults = make(DefaultDevices)
+
+	for _, flow := range dataFlows {
+		byRole := make(map[string]string)
+		s.Defaults[dataFlowString(flow)] = byRole
+
+		for _, role := range roles {
+			var immDevice *C.IMMDevice
+			hr := C.IMMDeviceEnumerator_GetDefaultAudioEndpoint(s.deviceEnum, flow, role, &immDevice)
+			if err := HRESULT(hr); errors.Is(err, ErrDeviceNotFound) {
+				byRole[roleString(role)] = ""
+				continue
+			} else if hr < 0 {
+				return fmt.Errorf("default %s %s device: %w", dataFlowString(flow), roleString(role), err)
+			}
+
+			device := &Device{device: immDevice}
+			err := device.getID()
+			device.release()
+			if err != nil {
+				return fmt.Errorf("default %s %s device: %w", dataFlowString(flow), roleString(role), err)
+			}
+
+			byRole[roleString(role)] = device.ID
+		}
+	}
+
+	return nil
+}
+
+// defaultCaptureDevice returns the ID of the device followed
+// in SelectionFollowDefault mode
+func (s *AudioService) defaultCaptureDevice() string {
+	return s.Defaults["capture"]["console"]
+}
+
+func (s *AudioService) handleDefaultDeviceChange(ev backendEvent) error {
+	s.record(HistoryEvent{
+		Time:     ev.time,
+		Kind:     HistoryDefaultDeviceChanged,
+		DeviceID: ev.deviceID,
+		Origin:   OriginExternal,
+		Detail:   ev.flow + " " + ev.role,
+	})
+
+	if s.Defaults[ev.flow] == nil {
+		s.Defaults[ev.flow] = make(map[string]string)
+	}
+	s.Defaults[ev.flow][ev.role] = ev.deviceID
+
+	if s.SelectionMode == SelectionFollowDefault && ev.flow == "capture" && ev.role == "console" {
+		return s.followDefault()
+	}
+
+	return s.updateFrontend(false, OriginExternal)
+}
+
+// followDefault selects the default capture device
+func (s *AudioService) followDefault() error {
+	id := s.defaultCaptureDevice()
+	if id == "" {
+		return s.updateFrontend(false, OriginExternal)
+	}
+
+	// The default device may be a new one whose
+	// addition has not been handled yet
+	if _, ok := s.Devices[id]; !ok {
+		_, err := s.addDevice(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.setDevice(id)
+}
+
+func (s *AudioService) SetSelectionMode(mode SelectionMode) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	switch mode {
+	case SelectionManual, SelectionFollowDefault:
+	default:
+		return fmt.Errorf("%w: %s", ErrSelectionMode, mode)
+	}
+
+	s.SelectionMode = mode
+	slog.Info("selection mode changed", "mode", mode)
+
+	if mode == SelectionFollowDefault {
+		return s.com.do(s.followDefault)
+	}
+	return s.updateFrontend(false, OriginSelf)
+}
+
+// SetSelectedAsDefault makes the selected device the system
+// default one for every role
+func (s *AudioService) SetSelectedAsDefault() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	device, ok := s.Devices[s.Selected]
+	if !ok || !device.isActive() {
+		return ErrDeviceNotFound
+	}
+
+	return s.com.do(func() error {
+		for _, role := range roles {
+			err := s.policy.SetDefaultEndpoint(device.ID, roleString(role))
+			if err != nil {
+				return err
+			}
+		}
+
+		slog.Info("selected device set as default", "device", device.ID)
+		return nil
+	})
+}
@@ -7,25 +7,61 @@ package main
 */
 import "C"
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"syscall"
 	"unsafe"
 )
 
 type DeviceState struct {
-	ID    string
-	Name  string
+	ID            string
+	Name          string
+	Description   string
+	InterfaceName string
+	Icon          string
+	FormFactor    string
+	JackSubType   string
+	// Enumerator is the name of the driver enumerator (USB, BTHENUM, ...)
+	// and Bus is its family (usb, bluetooth, ...)
+	Enumerator string
+	Bus        string
+	// ContainerID groups every device node of the same physical device
+	ContainerID string
+	State       string
+}
+
+// Values of DeviceState.State
+const (
+	DeviceActive     = "active"
+	DeviceDisabled   = "disabled"
+	DeviceNotPresent = "not-present"
+	DeviceUnplugged  = "unplugged"
+)
+
+// deviceOwner is the service a device reports back to
+type deviceOwner interface {
+	// deviceActivated receives the endpoint state read on activation
+	deviceActivated(d *Device, muted bool, volume float32)
+	// notificationHandle identifies the owner to the volume callbacks
+	notificationHandle() C.UINT_PTR
 }
 
 type Device struct {
+	owner deviceOwner
+
 	device   *C.IMMDevice
 	volume   *C.IAudioEndpointVolume
 	callback *C.IAudioEndpointVolumeCallback
 
+	meter       *C.IAudioMeterInformation
+	meterClient *C.IAudioClient
+
 	DeviceState
 }
 
-func newDevice(immDevice *C.IMMDevice) (*Device, error) {
-	device := &Device{device: immDevice}
+func newDevice(owner deviceOwner, immDevice *C.IMMDevice) (*Device, error) {
+	device := &Device{owner: owner, device: immDevice}
 
 	err := device.getID()
 	if err != nil {
@@ -33,20 +69,61 @@ func newDevice(immDevice *C.IMMDevice) (*Device, error) {
 		return nil, fmt.Errorf("device id: %w", err)
 	}
 
-	err = device.getName()
+	err = device.getProperties()
 	if err != nil {
 		device.release()
-		return nil, fmt.Errorf("device name: %w", err)
+		return nil, fmt.Errorf("device properties: %w", err)
+	}
+
+	err = device.getState()
+	if err != nil {
+		device.release()
+		return nil, fmt.Errorf("device state: %w", err)
 	}
 
 	return device, nil
 }
 
+// openDevice gets the device with the given id from the enumerator
+func openDevice(owner deviceOwner, deviceEnum *C.IMMDeviceEnumerator, id string) (*Device, error) {
+	immDevice, err := getIMMDevice(deviceEnum, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDevice(owner, immDevice)
+}
+
+func getIMMDevice(deviceEnum *C.IMMDeviceEnumerator, id string) (*C.IMMDevice, error) {
+	wid, err := syscall.UTF16PtrFromString(id)
+	if err != nil {
+		return nil, fmt.Errorf("device %s id: %w", id, err)
+	}
+
+	var immDevice *C.IMMDevice
+	hr := C.IMMDeviceEnumerator_GetDevice(deviceEnum, (C.LPCWSTR)(unsafe.Pointer(wid)), &immDevice)
+	if hr < 0 {
+		return nil, fmt.Errorf("device %s get: %w", id, HRESULT(hr))
+	}
+
+	return immDevice, nil
+}
+
+// isCapture reports whether the device is a recording endpoint
+func (d *Device) isCapture() (bool, error) {
+	var flow C.EDataFlow
+	if hr := C.IMMDevice_GetDataFlow(d.device, &flow); hr < 0 {
+		return false, fmt.Errorf("device %s data flow: %w", d.ID, HRESULT(hr))
+	}
+
+	return flow == C.eCapture, nil
+}
+
 func (d *Device) getID() error {
 	var id C.LPWSTR
 	hr := C.IMMDevice_GetId(d.device, &id)
 	if hr < 0 {
-		return fmt.Errorf("device id: 0x%x", uint32(hr))
+		return fmt.Errorf("device id: %w", HRESULT(hr))
 	}
 	defer C.freeUTF16String(id)
 
@@ -54,28 +131,161 @@ func (d *Device) getID() error {
 	return nil
 }
 
-func (d *Device) getName() error {
+func (d *Device) getState() error {
+	var state C.DWORD
+	if hr := C.IMMDevice_GetState(d.device, &state); hr < 0 {
+		return fmt.Errorf("device %s state: %w", d.ID, HRESULT(hr))
+	}
+
+	d.State = deviceStateString(uint32(state))
+	return nil
+}
+
+func (d *Device) isActive() bool {
+	return d.State == DeviceActive
+}
+
+// getProperties reads every property of the endpoint shown in DeviceState
+func (d *Device) getProperties() error {
+	if d.device == nil {
+		return fmt.Errorf("device %s property store: %w", d.ID, ErrDeviceGone)
+	}
+
 	var store *C.IPropertyStore
 	if hr := C.IMMDevice_OpenPropertyStore(d.device, &store); hr < 0 {
-		return fmt.Errorf("device %s property store: 0x%x", d.ID, uint32(hr))
+		return fmt.Errorf("device %s property store: %w", d.ID, HRESULT(hr))
 	}
 	defer C.IPropertyStore_Release(store)
 
-	var prop C.PROPVARIANT
-	if hr := C.IPropertyStore_GetValue(store, &C.PKEY_Device_FriendlyName, &prop); hr < 0 {
-		return fmt.Errorf("device %s name value: 0x%x", d.ID, uint32(hr))
+	var err error
+	d.Name, err = getStringProperty(store, &C.PKEY_Device_FriendlyName)
+	if err != nil {
+		return fmt.Errorf("device %s name value: %w", d.ID, err)
+	}
+
+	d.Description, err = getStringProperty(store, &C.PKEY_Device_DeviceDesc)
+	if err != nil {
+		return fmt.Errorf("device %s description value: %w", d.ID, err)
+	}
+
+	d.InterfaceName, err = getStringProperty(store, &C.PKEY_DeviceInterface_FriendlyName)
+	if err != nil {
+		return fmt.Errorf("device %s interface name value: %w", d.ID, err)
+	}
+
+	d.Icon, err = getStringProperty(store, &C.PKEY_DeviceClass_IconPath)
+	if err != nil {
+		return fmt.Errorf("device %s icon value: %w", d.ID, err)
+	}
+
+	d.JackSubType, err = getStringProperty(store, &C.PKEY_AudioEndpoint_JackSubType)
+	if err != nil {
+		return fmt.Errorf("device %s jack subtype value: %w", d.ID, err)
+	}
+
+	d.Enumerator, err = getStringProperty(store, &C.PKEY_Device_EnumeratorName)
+	if err != nil {
+		return fmt.Errorf("device %s enumerator value: %w", d.ID, err)
+	}
+	d.Bus = busString(d.Enumerator)
+
+	d.ContainerID, err = getGUIDProperty(store, &C.PKEY_Device_ContainerId)
+	if err != nil {
+		return fmt.Errorf("device %s container id value: %w", d.ID, err)
+	}
+
+	formFactor, ok, err := getUIntProperty(store, &C.PKEY_AudioEndpoint_FormFactor)
+	if err != nil {
+		return fmt.Errorf("device %s form factor value: %w", d.ID, err)
+	}
+	if ok {
+		d.FormFactor = formFactorString(formFactor)
+	} else {
+		d.FormFactor = ""
 	}
-	defer C.PropVariantClear(&prop) // Will handle also the name free
 
-	name := C.PROPVARIANT_GetStringValue(&prop)
-	d.Name = LPWSTRToStr(name)
 	return nil
 }
 
+// getStringProperty returns "" if the property is not set
+func getStringProperty(store *C.IPropertyStore, key *C.PROPERTYKEY) (string, error) {
+	var prop C.PROPVARIANT
+	if hr := C.IPropertyStore_GetValue(store, key, &prop); hr < 0 {
+		return "", HRESULT(hr)
+	}
+	defer C.PropVariantClear(&prop) // Will handle also the string free
+
+	value := C.PROPVARIANT_GetStringValue(&prop)
+	if value == nil {
+		return "", nil
+	}
+	return LPWSTRToStr(value), nil
+}
+
+// getUIntProperty reports whether the property is set
+func getUIntProperty(store *C.IPropertyStore, key *C.PROPERTYKEY) (uint32, bool, error) {
+	var prop C.PROPVARIANT
+	if hr := C.IPropertyStore_GetValue(store, key, &prop); hr < 0 {
+		return 0, false, HRESULT(hr)
+	}
+	defer C.PropVariantClear(&prop)
+
+	var value C.UINT
+	if C.PROPVARIANT_GetUIntValue(&prop, &value) == 0 {
+		return 0, false, nil
+	}
+	return uint32(value), true, nil
+}
+
+// getGUIDProperty returns "" if the property is not set
+func getGUIDProperty(store *C.IPropertyStore, key *C.PROPERTYKEY) (string, error) {
+	var prop C.PROPVARIANT
+	if hr := C.IPropertyStore_GetValue(store, key, &prop); hr < 0 {
+		return "", HRESULT(hr)
+	}
+	defer C.PropVariantClear(&prop)
+
+	var value C.GUID
+	if C.PROPVARIANT_GetGUIDValue(&prop, &value) == 0 {
+		return "", nil
+	}
+	return guidString(value), nil
+}
+
+func guidString(guid C.GUID) string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		uint32(guid.Data1), uint16(guid.Data2), uint16(guid.Data3),
+		guid.Data4[0], guid.Data4[1], guid.Data4[2], guid.Data4[3],
+		guid.Data4[4], guid.Data4[5], guid.Data4[6], guid.Data4[7],
+	)
+}
+
+// propertyName returns the name of the property of DeviceState
+// identified by key, or "" if it is not one of them
+func propertyName(key C.PROPERTYKEY) string {
+	switch key {
+	case C.PKEY_Device_FriendlyName:
+		return "name"
+	case C.PKEY_Device_DeviceDesc:
+		return "description"
+	case C.PKEY_DeviceInterface_FriendlyName:
+		return "interface-name"
+	case C.PKEY_DeviceClass_IconPath:
+		return "icon"
+	case C.PKEY_AudioEndpoint_FormFactor:
+		return "form-factor"
+	default:
+		return ""
+	}
+}
+
 func (d *Device) initVolume() error {
 	if d.volume != nil {
 		return nil
 	}
+	if d.device == nil {
+		return fmt.Errorf("device %s endpoint volume: %w", d.ID, ErrDeviceGone)
+	}
 
 	hr := C.IMMDevice_Activate(
 		d.device,
@@ -85,7 +295,7 @@ func (d *Device) initVolume() error {
 		(*unsafe.Pointer)(unsafe.Pointer(&d.volume)),
 	)
 	if hr < 0 {
-		return fmt.Errorf("device %s endpoint volume: 0x%x", d.ID, uint32(hr))
+		return fmt.Errorf("device %s endpoint volume: %w", d.ID, HRESULT(hr))
 	}
 
 	return nil
@@ -95,12 +305,8 @@ func (d *Device) releaseVolume() error {
 	if d.volume == nil {
 		return nil
 	}
-	defer func() { d.volume = nil }()
-
-	// This must be done to avoid the release function
-	// to block the thread, don't ask me why
-	volume := d.volume
-	go C.IAudioEndpointVolume_Release(volume)
+	C.IAudioEndpointVolume_Release(d.volume)
+	d.volume = nil
 
 	return nil
 }
@@ -113,14 +319,16 @@ func (d *Device) getMuted() (bool, error) {
 	var mutedInt C.int
 	hr := C.IAudioEndpointVolume_GetMute(d.volume, &mutedInt)
 	if hr < 0 {
-		return false, fmt.Errorf("device %s get mute: 0x%x", d.ID, uint32(hr))
+		return false, fmt.Errorf("device %s get mute: %w", d.ID, HRESULT(hr))
 	}
 	return mutedInt != 0, nil
 }
 
+// setMuted fails when the endpoint volume is missing: the mute state
+// must never change without the device being actually muted
 func (d *Device) setMuted(muted bool) error {
 	if d.volume == nil {
-		return nil
+		return fmt.Errorf("device %s set mute: %w", d.ID, ErrDeviceGone)
 	}
 
 	var cMuted C.BOOL
@@ -128,9 +336,35 @@ func (d *Device) setMuted(muted bool) error {
 		cMuted = 1
 	}
 
-	hr := C.IAudioEndpointVolume_SetMute(d.volume, cMuted, nil)
+	hr := C.IAudioEndpointVolume_SetMute(d.volume, cMuted, &eventContext)
 	if hr < 0 {
-		return fmt.Errorf("device %s get mute: 0x%x", d.ID, uint32(hr))
+		return fmt.Errorf("device %s set mute: %w", d.ID, HRESULT(hr))
+	}
+
+	return nil
+}
+
+func (d *Device) getVolume() (float32, error) {
+	if d.volume == nil {
+		return 0, nil
+	}
+
+	var level C.float
+	hr := C.IAudioEndpointVolume_GetMasterVolumeLevelScalar(d.volume, &level)
+	if hr < 0 {
+		return 0, fmt.Errorf("device %s get volume: %w", d.ID, HRESULT(hr))
+	}
+	return float32(level), nil
+}
+
+func (d *Device) setVolume(volume float32) error {
+	if d.volume == nil {
+		return nil
+	}
+
+	hr := C.IAudioEndpointVolume_SetMasterVolumeLevelScalar(d.volume, C.float(volume), &eventContext)
+	if hr < 0 {
+		return fmt.Errorf("device %s set volume: %w", d.ID, HRESULT(hr))
 	}
 
 	return nil
@@ -145,9 +379,9 @@ func (d *Device) registerControlChangeNotify() error {
 		return nil
 	}
 
-	hr := C.RegisterControlChangeNotify(d.volume, &d.callback)
+	hr := C.RegisterControlChangeNotify(d.volume, d.owner.notificationHandle(), &d.callback)
 	if hr < 0 {
-		return fmt.Errorf("device %s register notify: 0x%x", d.ID, uint32(hr))
+		return fmt.Errorf("device %s register notify: %w", d.ID, HRESULT(hr))
 	}
 
 	return nil
@@ -166,7 +400,7 @@ func (d *Device) unregisterControlChangeNotify() error {
 
 	hr := C.UnregisterControlChangeNotify(d.volume, d.callback)
 	if hr < 0 {
-		return fmt.Errorf("device %s unregister notify: 0x%x", d.ID, uint32(hr))
+		return fmt.Errorf("device %s unregister notify: %w", d.ID, HRESULT(hr))
 	}
 
 	return nil
@@ -178,11 +412,17 @@ func (d *Device) activate() error {
 		return err
 	}
 
-	audioService.Muted, err = d.getMuted()
+	muted, err := d.getMuted()
 	if err != nil {
 		return err
 	}
 
+	volume, err := d.getVolume()
+	if err != nil {
+		return err
+	}
+	d.owner.deviceActivated(d, muted, volume)
+
 	err = d.registerControlChangeNotify()
 	if err != nil {
 		return err
@@ -191,18 +431,122 @@ func (d *Device) activate() error {
 	return nil
 }
 
+// deactivate always releases the endpoint volume, even if the callback cannot
+// be unregistered: that is expected when the endpoint has been invalidated,
+// and keeping the stale interface would make initVolume reuse it
 func (d *Device) deactivate() error {
-	err := d.unregisterControlChangeNotify()
-	if err != nil {
-		return err
+	return errors.Join(
+		d.unregisterControlChangeNotify(),
+		d.releaseVolume(),
+	)
+}
+
+func deviceStateString(state uint32) string {
+	switch C.DWORD(state) {
+	case C.DEVICE_STATE_ACTIVE:
+		return DeviceActive
+	case C.DEVICE_STATE_DISABLED:
+		return DeviceDisabled
+	case C.DEVICE_STATE_NOTPRESENT:
+		return DeviceNotPresent
+	case C.DEVICE_STATE_UNPLUGGED:
+		return DeviceUnplugged
+	default:
+		return fmt.Sprintf("unknown (0x%x)", uint32(state))
+	}
+}
+
+func formFactorString(formFactor uint32) string {
+	switch C.EndpointFormFactor(formFactor) {
+	case C.RemoteNetworkDevice:
+		return "remote-network-device"
+	case C.Speakers:
+		return "speakers"
+	case C.LineLevel:
+		return "line-level"
+	case C.Headphones:
+		return "headphones"
+	case C.Microphone:
+		return "microphone"
+	case C.Headset:
+		return "headset"
+	case C.Handset:
+		return "handset"
+	case C.UnknownDigitalPassthrough:
+		return "digital-passthrough"
+	case C.SPDIF:
+		return "spdif"
+	case C.DigitalAudioDisplayDevice:
+		return "digital-display"
+	default:
+		return "unknown"
 	}
+}
 
-	err = d.releaseVolume()
+func busString(enumerator string) string {
+	switch strings.ToUpper(enumerator) {
+	case "":
+		return ""
+	case "USB":
+		return "usb"
+	case "BTHENUM", "BTHHFENUM", "BTHLEDEVICE":
+		return "bluetooth"
+	case "HDAUDIO", "INTELAUDIO":
+		return "hd-audio"
+	case "PCI":
+		return "pci"
+	case "SWD", "ROOT":
+		return "virtual"
+	default:
+		return "other"
+	}
+}
+
+func dataFlowString(flow C.EDataFlow) string {
+	switch flow {
+	case C.eRender:
+		return "render"
+	case C.eCapture:
+		return "capture"
+	default:
+		return "all"
+	}
+}
+
+func roleString(role C.ERole) string {
+	switch role {
+	case C.eConsole:
+		return "console"
+	case C.eMultimedia:
+		return "multimedia"
+	default:
+		return "communications"
+	}
+}
+
+// reacquire replaces the IMMDevice, and the endpoint volume if activate is
+// set, with new instances obtained from the device ID. It is used after
+// the old ones have been invalidated. If the device cannot be obtained
+// the old instances are kept, so that the device is never left without one
+func (d *Device) reacquire(deviceEnum *C.IMMDeviceEnumerator, activate bool) error {
+	immDevice, err := getIMMDevice(deviceEnum, d.ID)
 	if err != nil {
-		return err
+		return fmt.Errorf("reacquire: %w", err)
 	}
 
-	return nil
+	// The old interfaces are already unusable, errors are expected.
+	// The meter is started again when needed
+	d.stopMeter()
+	d.deactivate()
+	if d.device != nil {
+		C.IMMDevice_Release(d.device)
+	}
+	d.device = immDevice
+
+	if !activate {
+		return nil
+	}
+	return d.activate()
 }
 
 func (d *Device) copyStateFrom(other *Device) {
@@ -210,6 +554,10 @@ func (d *Device) copyStateFrom(other *Device) {
 }
 
 func (d *Device) release() {
+	d.stopMeter()
 	d.deactivate()
-	C.IMMDevice_Release(d.device)
+	if d.device != nil {
+		C.IMMDevice_Release(d.device)
+		d.device = nil
+	}
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// Publisher is where a service emits its events
+type Publisher interface {
+	Emit(name string, data ...any)
+}
+
+// EventBus carries the backend events to their listeners, so that the audio
+// logic does not depend on the Wails application, which is missing in headless mode
+type EventBus interface {
+	Publisher
+	// On registers a listener and returns the function to remove it
+	On(name string, callback func(data ...any)) func()
+}
+
+// wailsEventBus forwards the events to the application, reaching the frontend too.
+// The application is set once created, as it needs the services first
+type wailsEventBus struct {
+	app *application.App
+}
+
+func (b *wailsEventBus) Emit(name string, data ...any) {
+	b.app.EmitEvent(name, data...)
+}
+
+func (b *wailsEventBus) On(name string, callback func(data ...any)) func() {
+	return b.app.OnEvent(name, func(event *application.CustomEvent) {
+		data, _ := event.Data.([]any)
+		callback(data...)
+	})
+}
+
+// localEventBus delivers the events in process only
+type localEventBus struct {
+	listeners map[string]map[int]func(data ...any)
+	nextID    int
+	m         sync.Mutex
+}
+
+func newLocalEventBus() *localEventBus {
+	return &localEventBus{
+		listeners: make(map[string]map[int]func(data ...any)),
+	}
+}
+
+// Emit calls every listener in its own goroutine, like the application does
+func (b *localEventBus) Emit(name string, data ...any) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	for _, callback := range b.listeners[name] {
+		go callback(data...)
+	}
+}
+
+func (b *localEventBus) On(name string, callback func(data ...any)) func() {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	id := b.nextID
+	b.nextID++
+
+	if b.listeners[name] == nil {
+		b.listeners[name] = make(map[int]func(data ...any))
+	}
+	b.listeners[name][id] = callback
+
+	return func() {
+		b.m.Lock()
+		defer b.m.Unlock()
+
+		delete(b.listeners[name], id)
+	}
+}
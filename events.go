@@ -0,0 +1,310 @@
+package main
+
+/*
+#include "winaudio_wrapper.h"
+*/
+import "C"
+import (
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+type backendEventKind int
+
+const (
+	deviceAddedEvent backendEventKind = iota
+	deviceRemovedEvent
+	deviceStateChangedEvent
+	defaultDeviceChangedEvent
+	volumeChangedEvent
+	propertyChangedEvent
+	reconnectEvent
+)
+
+// backendEvent is a notification received from Core Audio, or a reconnect
+// attempt scheduled by the service. The callbacks only copy into it what they
+// need and enqueue it: the actual handling is done by AudioService.processEvents
+type backendEvent struct {
+	kind     backendEventKind
+	time     time.Time
+	deviceID string
+
+	// deviceStateChangedEvent
+	state uint32
+
+	// defaultDeviceChangedEvent
+	flow, role string
+
+	// propertyChangedEvent
+	property string
+
+	// volumeChangedEvent
+	muted  bool
+	volume float32
+	origin ChangeOrigin
+
+	// reconnectEvent, scheduled by withRecovery
+	attempt int
+}
+
+// DeviceAddedEvent, DeviceRemovedEvent and DeviceStateChangedEvent are
+// emitted for every change to the device list, before the full snapshot
+type DeviceAddedEvent struct {
+	Device DeviceState
+}
+
+type DeviceRemovedEvent struct {
+	DeviceID string
+}
+
+type DeviceStateChangedEvent struct {
+	DeviceID string
+	State    string
+}
+
+// DevicePropertyChangedEvent is emitted when a property shown
+// in DeviceState changes, Device holds the updated values
+type DevicePropertyChangedEvent struct {
+	DeviceID string
+	Property string
+	Device   DeviceState
+}
+
+const (
+	eventQueueSize      = 64
+	deviceEventDebounce = 150 * time.Millisecond
+)
+
+type eventQueue struct {
+	events   chan backendEvent
+	overflow atomic.Bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		events: make(chan backendEvent, eventQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// push enqueues ev without ever blocking the caller. If the queue is full
+// the event is dropped and a full resync is done with the next batch
+func (q *eventQueue) push(ev backendEvent) {
+	ev.time = time.Now()
+
+	select {
+	case q.events <- ev:
+	default:
+		q.overflow.Store(true)
+	}
+}
+
+// drain returns first together with every event already queued
+func (q *eventQueue) drain(first backendEvent) []backendEvent {
+	batch := []backendEvent{first}
+	for {
+		select {
+		case ev := <-q.events:
+			batch = append(batch, ev)
+		default:
+			return batch
+		}
+	}
+}
+
+// close stops the processing goroutine and waits for it to return
+func (q *eventQueue) close() {
+	close(q.stop)
+	<-q.done
+}
+
+// processEvents is the only consumer of the queue. Volume changes are
+// coalesced into the most recent one and handled right away, while device
+// events are debounced, so that a burst of them results in a single device
+// list refresh and a single frontend update
+func (s *AudioService) processEvents(q *eventQueue) {
+	defer close(q.done)
+
+	var pending []backendEvent
+	var resync bool
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case ev := <-q.events:
+			var volume *backendEvent
+			for _, ev := range q.drain(ev) {
+				switch ev.kind {
+				case volumeChangedEvent:
+					volume = &ev
+				case defaultDeviceChangedEvent:
+					s.handleEvent("default device change", func() error {
+						return s.handleDefaultDeviceChange(ev)
+					})
+				case reconnectEvent:
+					s.handleEvent("device reconnect", func() error {
+						return s.handleReconnect(ev)
+					})
+				default:
+					pending = append(pending, ev)
+				}
+			}
+
+			if volume != nil {
+				s.handleEvent("volume change", func() error {
+					return s.handleVolumeChange(*volume)
+				})
+			}
+
+			if q.overflow.Swap(false) {
+				slog.Warn("event queue overflow, resyncing devices")
+				resync = true
+			}
+
+			if (len(pending) > 0 || resync) && debounce == nil {
+				debounce = time.After(deviceEventDebounce)
+			}
+		case <-debounce:
+			events, full := pending, resync
+			pending, resync, debounce = nil, false, nil
+
+			s.handleEvent("device change", func() error {
+				return s.handleDeviceEvents(events, full)
+			})
+		}
+	}
+}
+
+// handleEvent runs fn on the COM thread while holding the service lock
+func (s *AudioService) handleEvent(op string, fn func() error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	err := s.com.do(fn)
+	if err != nil {
+		slog.Error("event handling failed", "op", op, "err", err)
+	}
+}
+
+// handleDeviceEvents updates only the devices named by the events,
+// unless a full resync is requested because some events were lost
+func (s *AudioService) handleDeviceEvents(events []backendEvent, resync bool) error {
+	// A saved device may come back with a new endpoint ID
+	var arrived bool
+
+	for _, ev := range events {
+		var err error
+		switch ev.kind {
+		case deviceAddedEvent:
+			err = s.handleDeviceAdded(ev.deviceID)
+			arrived = true
+		case deviceRemovedEvent:
+			s.handleDeviceRemoved(ev.deviceID)
+		case deviceStateChangedEvent:
+			err = s.handleDeviceStateChanged(ev)
+			arrived = arrived || ev.state == C.DEVICE_STATE_ACTIVE
+		case propertyChangedEvent:
+			err = s.handleDevicePropertyChanged(ev)
+		}
+
+		if errors.Is(err, ErrDeviceGone) {
+			// It will be followed by its own removal or state change
+			slog.Warn("device gone while handling its event", "device", ev.deviceID, "err", err)
+		} else if err != nil {
+			slog.Error("device event handling failed", "device", ev.deviceID, "err", err)
+			resync = true
+		}
+	}
+
+	// The full resync rebinds them on its own
+	if arrived && !resync {
+		err := s.rebindPrefs()
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.updateFrontend(resync, OriginExternal)
+}
+
+func (s *AudioService) handleDeviceAdded(id string) error {
+	added, err := s.addDevice(id)
+	if added {
+		s.publisher.Emit("audio-device-added", DeviceAddedEvent{Device: s.Devices[id].DeviceState})
+	}
+	return err
+}
+
+func (s *AudioService) handleDeviceRemoved(id string) {
+	if s.removeDevice(id) {
+		s.publisher.Emit("audio-device-removed", DeviceRemovedEvent{DeviceID: id})
+	}
+}
+
+func (s *AudioService) handleDeviceStateChanged(ev backendEvent) error {
+	state := deviceStateString(ev.state)
+
+	if _, ok := s.Devices[ev.deviceID]; !ok {
+		// Either not a recording device or one not seen yet
+		return s.handleDeviceAdded(ev.deviceID)
+	}
+
+	err := s.updateDeviceState(ev.deviceID, state)
+	s.record(HistoryEvent{
+		Time:     ev.time,
+		Kind:     HistoryDeviceStateChanged,
+		DeviceID: ev.deviceID,
+		Origin:   OriginExternal,
+		Detail:   state,
+	})
+	s.publisher.Emit("audio-device-state-changed", DeviceStateChangedEvent{DeviceID: ev.deviceID, State: state})
+
+	return err
+}
+
+func (s *AudioService) handleVolumeChange(ev backendEvent) error {
+	blocked, err := s.enforceMuteLock(ev.muted, ev.origin)
+	if blocked || err != nil {
+		return err
+	}
+
+	return s.mirrorVolumeChange(ev.muted, ev.volume, ev.origin)
+}
+
+// handleDevicePropertyChanged reads again the properties of the device,
+// saving them if it is a preference so that they do not go stale
+func (s *AudioService) handleDevicePropertyChanged(ev backendEvent) error {
+	device, ok := s.Devices[ev.deviceID]
+	if !ok {
+		return nil
+	}
+
+	err := device.getProperties()
+	if err != nil {
+		return err
+	}
+
+	s.publisher.Emit("audio-device-property-changed", DevicePropertyChangedEvent{
+		DeviceID: ev.deviceID,
+		Property: ev.property,
+		Device:   device.DeviceState,
+	})
+
+	if _, ok := s.Prefs[ev.deviceID]; ok {
+		s.Prefs[ev.deviceID] = device
+		return s.updateSaveData()
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+#include "feedback.h"
+
+#include <audioclient.h>
+
+// Plays mono 16 bit samples on the render device with the given id, or on the
+// default one if id is NULL, and returns when they have been played. The
+// samples must fit in a single buffer, which is the case for short cues
+HRESULT PlaySamples(IMMDeviceEnumerator* deviceEnum, LPCWSTR id, const short* samples, UINT32 count, UINT32 sampleRate) {
+	IMMDevice* device;
+	HRESULT hr;
+	if (id == NULL) {
+		hr = deviceEnum->GetDefaultAudioEndpoint(eRender, eConsole, &device);
+	} else {
+		hr = deviceEnum->GetDevice(id, &device);
+	}
+	if (FAILED(hr)) {
+		return hr;
+	}
+
+	IAudioClient* client;
+	hr = device->Activate(__uuidof(IAudioClient), CLSCTX_ALL, NULL, (void**)&client);
+	device->Release();
+	if (FAILED(hr)) {
+		return hr;
+	}
+
+	WAVEFORMATEX format = {};
+	format.wFormatTag = WAVE_FORMAT_PCM;
+	format.nChannels = 1;
+	format.nSamplesPerSec = sampleRate;
+	format.wBitsPerSample = 16;
+	format.nBlockAlign = format.nChannels * format.wBitsPerSample / 8;
+	format.nAvgBytesPerSec = format.nSamplesPerSec * format.nBlockAlign;
+
+	// Duration of the samples plus some margin, in 100ns units
+	REFERENCE_TIME duration = (REFERENCE_TIME)count * 10000000 / sampleRate;
+
+	hr = client->Initialize(
+		AUDCLNT_SHAREMODE_SHARED,
+		AUDCLNT_STREAMFLAGS_AUTOCONVERTPCM | AUDCLNT_STREAMFLAGS_SRC_DEFAULT_QUALITY,
+		duration + 1000000, 0, &format, NULL
+	);
+	if (FAILED(hr)) {
+		client->Release();
+		return hr;
+	}
+
+	UINT32 bufferFrames;
+	hr = client->GetBufferSize(&bufferFrames);
+	if (FAILED(hr)) {
+		client->Release();
+		return hr;
+	}
+	if (count > bufferFrames) {
+		count = bufferFrames;
+	}
+
+	IAudioRenderClient* render;
+	hr = client->GetService(__uuidof(IAudioRenderClient), (void**)&render);
+	if (FAILED(hr)) {
+		client->Release();
+		return hr;
+	}
+
+	BYTE* buffer;
+	hr = render->GetBuffer(count, &buffer);
+	if (SUCCEEDED(hr)) {
+		CopyMemory(buffer, samples, count * format.nBlockAlign);
+		hr = render->ReleaseBuffer(count, 0);
+	}
+
+	if (SUCCEEDED(hr)) {
+		hr = client->Start();
+	}
+	if (SUCCEEDED(hr)) {
+		Sleep((DWORD)(duration / 10000) + 50);
+		client->Stop();
+	}
+
+	render->Release();
+	client->Release();
+	return hr;
+}
@@ -0,0 +1,291 @@
+package main
+
+/*
+#include "winaudio_wrapper.h"
+#include "feedback.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Cue is a feedback sound
+type Cue string
+
+const (
+	CueMute   Cue = "mute"
+	CueUnmute Cue = "unmute"
+	CueError  Cue = "error"
+)
+
+type FeedbackConfig struct {
+	Enabled bool
+	// DeviceID is the render device the cues are played on, "" for the default one
+	DeviceID string
+	// Volume goes from 0 to 1
+	Volume float32
+}
+
+var defaultFeedbackConfig = FeedbackConfig{
+	Volume: 0.5,
+}
+
+var (
+	ErrFeedbackConfig = errors.New("invalid feedback config")
+	ErrPlayerBusy     = errors.New("feedback player busy")
+)
+
+// Player plays the feedback cues. Play must not wait for the cue to be played
+type Player interface {
+	Play(cue Cue, deviceID string, volume float32) error
+	Close() error
+}
+
+const (
+	toneSampleRate = 48000
+	toneFade       = 5 * time.Millisecond
+)
+
+// tone is a sine wave, or silence if freq is 0
+type tone struct {
+	freq     float64
+	duration time.Duration
+}
+
+var cueTones = map[Cue][]tone{
+	CueMute:   {{660, 70 * time.Millisecond}, {440, 90 * time.Millisecond}},
+	CueUnmute: {{440, 70 * time.Millisecond}, {660, 90 * time.Millisecond}},
+	CueError:  {{220, 120 * time.Millisecond}, {0, 60 * time.Millisecond}, {220, 120 * time.Millisecond}},
+}
+
+// generateCue renders the tones as mono 16 bit samples at full scale,
+// fading every tone in and out to avoid clicks
+func generateCue(tones []tone) []int16 {
+	var samples []int16
+	fade := int(toneFade.Seconds() * toneSampleRate)
+
+	for _, t := range tones {
+		n := int(t.duration.Seconds() * toneSampleRate)
+		for i := range n {
+			if t.freq == 0 {
+				samples = append(samples, 0)
+				continue
+			}
+
+			gain := 1.0
+			if i < fade {
+				gain = float64(i) / float64(fade)
+			} else if n-i < fade {
+				gain = float64(n-i) / float64(fade)
+			}
+
+			v := math.Sin(2 * math.Pi * t.freq * float64(i) / toneSampleRate)
+			samples = append(samples, int16(v*gain*math.MaxInt16))
+		}
+	}
+
+	return samples
+}
+
+type playRequest struct {
+	samples  []int16
+	deviceID string
+}
+
+// tonePlayer plays the generated cues on its own COM thread,
+// one at a time, dropping the ones requested while busy
+type tonePlayer struct {
+	cues       map[Cue][]int16
+	com        *comThread
+	deviceEnum *C.IMMDeviceEnumerator
+
+	reqs chan playRequest
+	done chan struct{}
+}
+
+func newTonePlayer() (*tonePlayer, error) {
+	com, err := startCOMThread()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &tonePlayer{
+		cues: make(map[Cue][]int16),
+		com:  com,
+		reqs: make(chan playRequest, 1),
+		done: make(chan struct{}),
+	}
+
+	err = com.do(func() error {
+		if hr := C.CreateInstance(&p.deviceEnum); hr < 0 {
+			return fmt.Errorf("feedback device enumerator create: %w", HRESULT(hr))
+		}
+		return nil
+	})
+	if err != nil {
+		com.stop()
+		return nil, err
+	}
+
+	for cue, tones := range cueTones {
+		p.cues[cue] = generateCue(tones)
+	}
+
+	go p.run()
+	return p, nil
+}
+
+func (p *tonePlayer) run() {
+	defer close(p.done)
+
+	for req := range p.reqs {
+		err := p.com.do(func() error {
+			return p.play(req)
+		})
+		if err != nil {
+			slog.Warn("feedback cue failed", "device", req.deviceID, "err", err)
+		}
+	}
+}
+
+func (p *tonePlayer) play(req playRequest) error {
+	var id *uint16
+	if req.deviceID != "" {
+		var err error
+		id, err = syscall.UTF16PtrFromString(req.deviceID)
+		if err != nil {
+			return fmt.Errorf("device %s id: %w", req.deviceID, err)
+		}
+	}
+
+	hr := C.PlaySamples(
+		p.deviceEnum,
+		(C.LPCWSTR)(unsafe.Pointer(id)),
+		(*C.short)(unsafe.Pointer(&req.samples[0])),
+		C.UINT32(len(req.samples)),
+		toneSampleRate,
+	)
+	if hr < 0 {
+		return fmt.Errorf("feedback play: %w", HRESULT(hr))
+	}
+	return nil
+}
+
+func (p *tonePlayer) Play(cue Cue, deviceID string, volume float32) error {
+	cueSamples, ok := p.cues[cue]
+	if !ok {
+		return fmt.Errorf("unknown cue %s", cue)
+	}
+
+	samples := make([]int16, len(cueSamples))
+	for i, v := range cueSamples {
+		samples[i] = int16(float32(v) * volume)
+	}
+
+	select {
+	case p.reqs <- playRequest{samples: samples, deviceID: deviceID}:
+		return nil
+	default:
+		return ErrPlayerBusy
+	}
+}
+
+func (p *tonePlayer) Close() error {
+	close(p.reqs)
+	<-p.done
+
+	p.com.do(func() error {
+		C.IMMDeviceEnumerator_Release(p.deviceEnum)
+		p.deviceEnum = nil
+		return nil
+	})
+	p.com.stop()
+	return nil
+}
+
+// playCue plays the cue if the feedback is enabled
+func (s *AudioService) playCue(cue Cue) {
+	if !s.Feedback.Enabled || s.player == nil {
+		return
+	}
+
+	err := s.player.Play(cue, s.Feedback.DeviceID, s.Feedback.Volume)
+	if err != nil {
+		slog.Warn("feedback cue not played", "cue", cue, "err", err)
+	}
+}
+
+// playToggleCue plays the cue for the outcome of a mute toggle,
+// or nothing if the mute state did not change
+func (s *AudioService) playToggleCue(wasMuted bool, err error) {
+	switch {
+	case err != nil:
+		s.playCue(CueError)
+	case s.Muted == wasMuted:
+	case s.Muted:
+		s.playCue(CueMute)
+	default:
+		s.playCue(CueUnmute)
+	}
+}
+
+func (cfg FeedbackConfig) validate() error {
+	if cfg.Volume < 0 || cfg.Volume > 1 {
+		return fmt.Errorf("%w: volume %v out of [0, 1]", ErrFeedbackConfig, cfg.Volume)
+	}
+	return nil
+}
+
+// SetFeedbackConfig changes the feedback cues config, playing
+// the unmute cue as a preview if they are enabled
+func (s *AudioService) SetFeedbackConfig(cfg FeedbackConfig) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	s.Feedback = cfg
+	slog.Info("feedback config changed", "enabled", cfg.Enabled, "device", cfg.DeviceID, "volume", cfg.Volume)
+
+	s.playCue(CueUnmute)
+	return s.updateFrontend(false, OriginSelf)
+}
+
+// GetRenderDevices returns the active playback devices, where the cues can be played
+func (s *AudioService) GetRenderDevices() ([]DeviceState, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return nil, ErrAudioServiceNotRunning
+	}
+
+	var states []DeviceState
+	err := s.com.do(func() error {
+		devices, err := s.getDeviceCollection(C.eRender, C.DEVICE_STATE_ACTIVE)
+		if err != nil {
+			return err
+		}
+
+		for _, device := range devices {
+			states = append(states, device.DeviceState)
+			device.release()
+		}
+		return nil
+	})
+
+	return states, err
+}
@@ -0,0 +1,17 @@
+#ifndef FEEDBACK_H
+#define FEEDBACK_H
+
+#include <windows.h>
+#include <mmdeviceapi.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif // __cplusplus
+
+	HRESULT PlaySamples(IMMDeviceEnumerator* deviceEnum, LPCWSTR id, const short* samples, UINT32 count, UINT32 sampleRate);
+
+#ifdef __cplusplus
+}
+#endif // __cplusplus
+
+#endif // FEEDBACK_H
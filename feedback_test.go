@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// recordingPlayer is a Player that remembers the cues instead of playing them
+type recordingPlayer struct {
+	cues   []Cue
+	closed bool
+}
+
+func (p *recordingPlayer) Play(cue Cue, deviceID string, volume float32) error {
+	p.cues = append(p.cues, cue)
+	return nil
+}
+
+func (p *recordingPlayer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func newFeedbackTestService(t *testing.T, player Player) *AudioService {
+	dir := t.TempDir()
+	s := newAudioService(newLocalEventBus(), filepath.Join(dir, "audio_save.json"), filepath.Join(dir, "history.jsonl"))
+	s.Feedback = FeedbackConfig{Enabled: true, Volume: 0.5}
+	s.player = player
+	return s
+}
+
+func TestPlayToggleCue(t *testing.T) {
+	tests := []struct {
+		name     string
+		wasMuted bool
+		muted    bool
+		err      error
+		want     []Cue
+	}{
+		{"mute", false, true, nil, []Cue{CueMute}},
+		{"unmute", true, false, nil, []Cue{CueUnmute}},
+		{"error", false, false, ErrDeviceNotFound, []Cue{CueError}},
+		{"unchanged while unmuted", false, false, nil, nil},
+		{"unchanged while muted", true, true, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &recordingPlayer{}
+			s := newFeedbackTestService(t, player)
+			s.Muted = tt.muted
+
+			s.playToggleCue(tt.wasMuted, tt.err)
+
+			if !slices.Equal(player.cues, tt.want) {
+				t.Errorf("played %v, want %v", player.cues, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayCueDisabled(t *testing.T) {
+	player := &recordingPlayer{}
+	s := newFeedbackTestService(t, player)
+	s.Feedback.Enabled = false
+
+	s.playToggleCue(false, errors.New("toggle failed"))
+	s.Muted = true
+	s.playToggleCue(false, nil)
+
+	if len(player.cues) > 0 {
+		t.Errorf("played %v with the feedback disabled", player.cues)
+	}
+}
+
+func TestToggleSelectedCues(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs map[string]*Device
+		want  []Cue
+	}{
+		// The selected preference is saved but not connected: nothing changes
+		{"absent pref", map[string]*Device{"saved": {}}, nil},
+		// Nothing is selected at all
+		{"no device", map[string]*Device{}, []Cue{CueError}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &recordingPlayer{}
+			s := newFeedbackTestService(t, player)
+			s.Prefs = tt.prefs
+			s.Selected = "saved"
+
+			var err error
+			s.com, err = startCOMThread()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.com.stop()
+			s.running = true
+
+			err = s.ToggleSelected()
+			if (err != nil) != slices.Contains(tt.want, CueError) {
+				t.Errorf("unexpected toggle result: %v", err)
+			}
+			if s.Muted {
+				t.Error("muted state changed")
+			}
+			if !slices.Equal(player.cues, tt.want) {
+				t.Errorf("played %v, want %v", player.cues, tt.want)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-import { AudioService, WindowService } from "../../bindings/github.com/nixpare/AudioSwitch";
+import { AudioService, WindowService, LogService } from "../../bindings/github.com/nixpare/AudioSwitch";
 import * as types from "../../bindings/github.com/nixpare/AudioSwitch";
 import * as wails from "@wailsio/runtime";
 
@@ -17,6 +17,30 @@ wails.Events.On("audio-device-update", (ev) => {
     setAppState(reconcile(newState, { merge: true }))
 });
 
+const [levels, setLevels] = createStore({})
+
+wails.Events.On("audio-level", (ev) => {
+    setLevels(reconcile(ev.data[0].Levels))
+});
+
+// metering runs only while the dashboard is visible
+let levelsSubscribed = false
+
+function updateLevelsSubscription() {
+    const visible = document.visibilityState === 'visible'
+    if (visible === levelsSubscribed) return
+
+    levelsSubscribed = visible
+    // the backend drops the subscription when the window is closed
+    WindowService.SetDashboardMetering(visible).catch(err => console.error(err))
+    if (!visible) {
+        setLevels(reconcile({}))
+    }
+}
+
+updateLevelsSubscription()
+document.addEventListener('visibilitychange', updateLevelsSubscription)
+
 window.addEventListener('resize', (ev) => {
     wails.Events.Emit({ name: "window-resize" })
 })
@@ -43,15 +67,22 @@ createEffect(() => {
     Object.entries(appState.Prefs).forEach(([key, value]) => {
         setDevices(key, reconcile({
             ...value,
+            // a preference is not present until the system reports it
+            State: appState.Devices[key]?.State ?? 'not-present',
             pref: true
         }, { merge: true }))
     })
 })
 
 function DeviceList() {
+    // inactive devices are shown only if starred, devices of the same kind are kept together
+    const visible = () => Object.values(devices)
+        .filter(device => device.State === 'active' || appState.Prefs[device.ID])
+        .sort((a, b) => (a.FormFactor ?? '').localeCompare(b.FormFactor ?? '') || a.Name.localeCompare(b.Name))
+
     return (
         <>
-            <For each={Object.values(devices)} >{
+            <For each={visible()} >{
                 (device) => <Device device={device} />
             }</For>
         </>
@@ -84,7 +115,16 @@ function SelectedDevice() {
     return (
         <>
             <h3>Select device:</h3>
-            <div class="device" style={`min-width: ${size()}px`}>{selected()}</div>
+            <div class="device" style={`min-width: ${size()}px`}>
+                {selected()}
+                <Show when={appState.Reconnect}>
+                    <div class="reconnect">{
+                        appState.Reconnect.Failed
+                            ? 'reconnect failed'
+                            : `reconnecting (${appState.Reconnect.Attempt}/${appState.Reconnect.MaxAttempts})`
+                    }</div>
+                </Show>
+            </div>
             <button class="btn" onclick={toggleSelected}>
                 <Show when={!appState.Muted}>
                     <svg xmlns="http://www.w3.org/2000/svg"
@@ -101,18 +141,111 @@ function SelectedDevice() {
                     </svg>
                 </Show>
             </button>
+            <MuteLockButton />
+        </>
+    )
+}
+
+function MuteLockButton() {
+    async function toggleMuteLock() {
+        await AudioService.SetMuteLock(!appState.MuteLock);
+    }
+
+    return (
+        <button class={`btn ${appState.MuteLock ? 'active' : ''}`} onclick={toggleMuteLock} mute-lock-button
+            title={appState.MuteLock ? 'Mute lock engaged' : 'Mute lock released'}>
+            <Show when={!appState.MuteLock}>
+                <svg xmlns="http://www.w3.org/2000/svg"
+                    viewBox="0 0 576 512">{/*<!--!Font Awesome Free 6.6.0 by @fontawesome - https://fontawesome.com License - https://fontawesome.com/license/free Copyright 2024 Fonticons, Inc.-->*/}
+                    <path
+                        d="M352 144c0-44.2 35.8-80 80-80s80 35.8 80 80l0 48c0 17.7 14.3 32 32 32s32-14.3 32-32l0-48C576 64.5 511.5 0 432 0S288 64.5 288 144l0 48L64 192c-35.3 0-64 28.7-64 64L0 448c0 35.3 28.7 64 64 64l320 0c35.3 0 64-28.7 64-64l0-192c0-35.3-28.7-64-64-64l-32 0 0-48z" />
+                </svg>
+            </Show>
+            <Show when={appState.MuteLock}>
+                <svg xmlns="http://www.w3.org/2000/svg"
+                    viewBox="0 0 448 512">{/*<!--!Font Awesome Free 6.6.0 by @fontawesome - https://fontawesome.com License - https://fontawesome.com/license/free Copyright 2024 Fonticons, Inc.-->*/}
+                    <path
+                        d="M144 144l0 48 160 0 0-48c0-44.2-35.8-80-80-80s-80 35.8-80 80zM80 192l0-48C80 64.5 144.5 0 224 0s144 64.5 144 144l0 48 16 0c35.3 0 64 28.7 64 64l0 192c0 35.3-28.7 64-64 64L64 512c-35.3 0-64-28.7-64-64L0 256c0-35.3 28.7-64 64-64l16 0z" />
+                </svg>
+            </Show>
+        </button>
+    )
+}
+
+function DefaultDevice() {
+    const followDefault = () => appState.SelectionMode === 'follow-default'
+
+    async function toggleFollowDefault() {
+        await AudioService.SetSelectionMode(followDefault() ? 'manual' : 'follow-default')
+    }
+
+    async function setAsDefault() {
+        await AudioService.SetSelectedAsDefault()
+    }
+
+    return (
+        <>
+            <button class={`btn ${followDefault() ? 'active' : ''}`} onclick={toggleFollowDefault}>
+                Follow system default
+            </button>
+            <button class="btn" onclick={setAsDefault}
+                disabled={followDefault() || appState.Defaults?.capture?.console === appState.Selected}>
+                Set as system default
+            </button>
         </>
     )
 }
 
+const formFactorLabels = {
+    'remote-network-device': 'Network',
+    'speakers': 'Speakers',
+    'line-level': 'Line in',
+    'headphones': 'Headphones',
+    'microphone': 'Microphone',
+    'headset': 'Headset',
+    'handset': 'Handset',
+    'digital-passthrough': 'Digital',
+    'spdif': 'S/PDIF',
+    'digital-display': 'Display',
+}
+
+const busLabels = {
+    'usb': 'USB',
+    'bluetooth': 'Bluetooth',
+    'hd-audio': 'HD Audio',
+    'pci': 'PCI',
+    'virtual': 'Virtual',
+}
+
+// deviceLabel describes the kind of device, e.g. "Headset – USB"
+function deviceLabel(device) {
+    return [formFactorLabels[device.FormFactor], busLabels[device.Bus]]
+        .filter(label => label)
+        .join(' – ')
+}
+
 function Device(props) {
     async function setDevice() {
         await AudioService.SetDevice(props.device.ID);
     }
 
     return (
-        <li id={props.device.ID} class="btn" device onclick={setDevice}>
-            {props.device.Name}
+        <li id={props.device.ID} class={`btn ${props.device.State === 'active' ? '' : 'inactive'}`} device onclick={setDevice}
+            title={props.device.State === 'active' ? props.device.Description : props.device.State}>
+            <div class="name">
+                {props.device.Name}
+                <Show when={deviceLabel(props.device)}>
+                    <span class="label">{deviceLabel(props.device)}</span>
+                </Show>
+                <Show when={props.device.State === 'active'}>
+                    <div class="level">
+                        <div class="bar" style={`width: ${Math.round((levels[props.device.ID] ?? 0) * 100)}%`}></div>
+                    </div>
+                </Show>
+            </div>
+            <Show when={appState.Defaults?.capture?.console === props.device.ID}>
+                <span class="default">default</span>
+            </Show>
             <PrefButton id={props.device.ID} pref={props.device.pref} />
         </li>
     )
@@ -212,6 +345,247 @@ function HotkeyManager() {
     )
 }
 
+function SpeechDetect() {
+    async function update(changes) {
+        await AudioService.SetSpeechDetectConfig({ ...appState.SpeechDetect, ...changes })
+    }
+
+    return (
+        <Show when={appState.SpeechDetect}>
+            <label>
+                <input type="checkbox" checked={appState.SpeechDetect.Enabled}
+                    onchange={ev => update({ Enabled: ev.target.checked })} />
+                Warn when speaking while muted
+            </label>
+            <label>
+                Threshold
+                <input type="range" min="0.01" max="1" step="0.01" value={appState.SpeechDetect.Threshold}
+                    onchange={ev => update({ Threshold: Number(ev.target.value) })} />
+            </label>
+            <label>
+                Hold (ms)
+                <input class="btn" type="number" min="0" step="100" value={appState.SpeechDetect.HoldMillis}
+                    onchange={ev => update({ HoldMillis: Number(ev.target.value) })} />
+            </label>
+            <select class="btn" value={appState.SpeechDetect.Policy} onchange={ev => update({ Policy: ev.target.value })}>
+                <option value="notify">notify</option>
+                <option value="sound">play a sound</option>
+                <option value="auto-unmute">auto-unmute</option>
+            </select>
+        </Show>
+    )
+}
+
+function VOX() {
+    async function update(changes) {
+        await AudioService.SetVOXConfig({ ...appState.VOX, ...changes })
+    }
+
+    return (
+        <Show when={appState.VOX}>
+            <label>
+                <input type="checkbox" checked={appState.VOX.Enabled}
+                    onchange={ev => update({ Enabled: ev.target.checked })} />
+                Voice activated (VOX)
+            </label>
+            <label>
+                Threshold
+                <input type="range" min="0.01" max="1" step="0.01" value={appState.VOX.Threshold}
+                    onchange={ev => update({ Threshold: Number(ev.target.value) })} />
+            </label>
+            <label>
+                Attack (ms)
+                <input class="btn" type="number" min="0" step="50" value={appState.VOX.AttackMillis}
+                    onchange={ev => update({ AttackMillis: Number(ev.target.value) })} />
+            </label>
+            <label>
+                Hang (ms)
+                <input class="btn" type="number" min="0" step="100" value={appState.VOX.HangMillis}
+                    onchange={ev => update({ HangMillis: Number(ev.target.value) })} />
+            </label>
+        </Show>
+    )
+}
+
+function Feedback() {
+    const [renderDevices, setRenderDevices] = createSignal([])
+
+    async function loadRenderDevices() {
+        setRenderDevices(await AudioService.GetRenderDevices() ?? [])
+    }
+    loadRenderDevices().catch(err => console.error(err))
+
+    async function update(changes) {
+        await AudioService.SetFeedbackConfig({ ...appState.Feedback, ...changes })
+    }
+
+    return (
+        <Show when={appState.Feedback}>
+            <label>
+                <input type="checkbox" checked={appState.Feedback.Enabled}
+                    onchange={ev => update({ Enabled: ev.target.checked })} />
+                Feedback sounds
+            </label>
+            <label>
+                Volume
+                <input type="range" min="0" max="1" step="0.05" value={appState.Feedback.Volume}
+                    onchange={ev => update({ Volume: Number(ev.target.value) })} />
+            </label>
+            <select class="btn" value={appState.Feedback.DeviceID} onfocus={loadRenderDevices}
+                onchange={ev => update({ DeviceID: ev.target.value })}>
+                <option value="">default output</option>
+                <For each={renderDevices()}>{
+                    (device) => <option value={device.ID}>{device.Name}</option>
+                }</For>
+            </select>
+        </Show>
+    )
+}
+
+function OverlaySettings() {
+    const [config, setConfig] = createStore(new types.OverlayState())
+    const [screens, setScreens] = createSignal([])
+
+    WindowService.GetOverlayConfig()
+        .then(config => setConfig(reconcile(config)))
+        .catch(err => console.error(err))
+
+    wails.Events.On("overlay-config-update", (ev) => {
+        setConfig(reconcile(ev.data[0]))
+    });
+
+    async function loadScreens() {
+        setScreens(await wails.Screens.GetAll() ?? [])
+    }
+    loadScreens().catch(err => console.error(err))
+
+    async function update(changes) {
+        await WindowService.SetOverlayConfig({ ...config, ...changes })
+    }
+
+    return (
+        <>
+            <select class="btn" value={config.Anchor} onchange={ev => update({ Anchor: ev.target.value })}>
+                <option value="">free position</option>
+                <option value="top-left">top left</option>
+                <option value="top-right">top right</option>
+                <option value="bottom-left">bottom left</option>
+                <option value="bottom-right">bottom right</option>
+            </select>
+            <Show when={config.Anchor}>
+                <select class="btn" value={config.Screen} onfocus={loadScreens}
+                    onchange={ev => update({ Screen: ev.target.value })}>
+                    <option value="">primary screen</option>
+                    <For each={screens()}>{
+                        (screen) => <option value={screen.ID}>{screen.Name}</option>
+                    }</For>
+                </select>
+                <label>
+                    Margin
+                    <input class="btn" type="number" min="0" step="5" value={config.MarginX}
+                        onchange={ev => update({ MarginX: Number(ev.target.value) })} />
+                    <input class="btn" type="number" min="0" step="5" value={config.MarginY}
+                        onchange={ev => update({ MarginY: Number(ev.target.value) })} />
+                </label>
+            </Show>
+            <label>
+                Size
+                <input type="range" min="0.5" max="3" step="0.1" value={config.Scale}
+                    onchange={ev => update({ Scale: Number(ev.target.value) })} />
+            </label>
+            <label>
+                Opacity
+                <input type="range" min="0.2" max="1" step="0.05" value={config.Opacity}
+                    onchange={ev => update({ Opacity: Number(ev.target.value) })} />
+            </label>
+            <label>
+                <input type="checkbox" checked={config.ClickThrough}
+                    onchange={ev => update({ ClickThrough: ev.target.checked })} />
+                Click-through
+            </label>
+            <label>
+                <input type="checkbox" checked={config.AutoHide}
+                    onchange={ev => update({ AutoHide: ev.target.checked })} />
+                Show only while muted
+            </label>
+        </>
+    )
+}
+
+function Startup() {
+    const [config, setConfig] = createStore(new types.StartupConfig())
+
+    WindowService.GetStartupConfig()
+        .then(config => setConfig(reconcile(config)))
+        .catch(err => console.error(err))
+
+    async function update(changes) {
+        const newConfig = { ...config, ...changes }
+        await WindowService.SetStartupConfig(newConfig)
+        setConfig(reconcile(newConfig))
+    }
+
+    return (
+        <>
+            <label>
+                <input type="checkbox" checked={config.Autostart}
+                    onchange={ev => update({ Autostart: ev.target.checked })} />
+                Start at login
+            </label>
+            <label>
+                <input type="checkbox" checked={config.StartMinimised}
+                    onchange={ev => update({ StartMinimised: ev.target.checked })} />
+                Start minimised
+            </label>
+        </>
+    )
+}
+
+function HistoryExport() {
+    async function exportHistory(format) {
+        const path = await wails.Dialogs.SaveFile({
+            Title: "Export history",
+            Filename: `audioswitch-history.${format}`,
+            Filters: [{ DisplayName: format.toUpperCase(), Pattern: `*.${format}` }],
+        })
+        if (!path) return
+
+        await AudioService.ExportHistory(path, format)
+    }
+
+    return (
+        <>
+            <span>Export history:</span>
+            <button class="btn" onclick={() => exportHistory('jsonl')}>JSON Lines</button>
+            <button class="btn" onclick={() => exportHistory('csv')}>CSV</button>
+        </>
+    )
+}
+
+function LogLevel() {
+    const [level, setLevel] = createSignal('INFO')
+
+    LogService.GetLogLevel()
+        .then(level => setLevel(level))
+        .catch(err => console.error(err))
+
+    async function changeLevel(ev) {
+        await LogService.SetLogLevel(ev.target.value)
+        setLevel(await LogService.GetLogLevel())
+    }
+
+    return (
+        <>
+            <span>Log level:</span>
+            <select class="btn" value={level()} onchange={changeLevel}>
+                <For each={['DEBUG', 'INFO', 'WARN', 'ERROR']}>{
+                    (level) => <option value={level}>{level.toLowerCase()}</option>
+                }</For>
+            </select>
+        </>
+    )
+}
+
 function ExitButton() {
     function exit() {
         WindowService.Exit();
@@ -223,6 +597,14 @@ function ExitButton() {
 }
 
 render(() => <SelectedDevice />, document.querySelector('[selected-device]'))
+render(() => <DefaultDevice />, document.querySelector('[default-device]'))
 render(() => <DeviceList />, document.querySelector('[device-list]'))
 render(() => <HotkeyManager />, document.querySelector('[hotkey-manager]'))
+render(() => <SpeechDetect />, document.querySelector('[speech-detect]'))
+render(() => <VOX />, document.querySelector('[vox]'))
+render(() => <Feedback />, document.querySelector('[feedback]'))
+render(() => <OverlaySettings />, document.querySelector('[overlay-settings]'))
+render(() => <Startup />, document.querySelector('[startup]'))
+render(() => <HistoryExport />, document.querySelector('[history-export]'))
+render(() => <LogLevel />, document.querySelector('[log-level]'))
 render(() => <ExitButton />, document.querySelector('[exit-button]'))
@@ -5,13 +5,77 @@ import { render } from "solid-js/web";
 import { createEffect, createSignal, onMount, Show } from "solid-js";
 
 const [muted, setMuted] = createSignal(false)
+const [reconnect, setReconnect] = createSignal(null)
 
 AudioService.GetState()
-	.then(state => setMuted(state.Muted))
+	.then(state => {
+		setMuted(state.Muted)
+		setReconnect(state.Reconnect)
+	})
 	.catch(err => console.error(err))
 
 wails.Events.On("audio-device-update", (ev) => {
 	setMuted(ev.data[0].Muted)
+	setReconnect(ev.data[0].Reconnect)
+});
+
+const [blocked, setBlocked] = createSignal('')
+let blockedTimeout;
+
+wails.Events.On("audio-mute-blocked", (ev) => {
+	setBlocked(ev.data[0].Muted ? 'blocked unmute' : 'blocked mute')
+
+	if (blockedTimeout) {
+		window.clearTimeout(blockedTimeout)
+	}
+
+	blockedTimeout = window.setTimeout(() => {
+		setBlocked('')
+		blockedTimeout = undefined;
+	}, 2000)
+});
+
+const [level, setLevel] = createSignal(0)
+
+wails.Events.On("audio-level", (ev) => {
+	setLevel(ev.data[0].Selected)
+});
+
+// the backend subscribes to the selected level while the overlay is shown
+
+const [speaking, setSpeaking] = createSignal(false)
+let speakingTimeout;
+
+wails.Events.On("speaking-while-muted", () => {
+	setSpeaking(true)
+
+	if (speakingTimeout) {
+		window.clearTimeout(speakingTimeout)
+	}
+
+	speakingTimeout = window.setTimeout(() => {
+		setSpeaking(false)
+		speakingTimeout = undefined;
+	}, 2000)
+});
+
+createEffect(() => {
+	document.body.classList.toggle('speaking-while-muted', speaking())
+})
+
+// scale and opacity are set from the dashboard, the position is handled by the backend
+function applyConfig(config) {
+	document.documentElement.style.fontSize = `${config.Scale * 100}%`
+	document.body.style.opacity = config.Opacity
+	resizeWindow().catch(err => console.error(err))
+}
+
+WindowService.GetOverlayConfig()
+	.then(applyConfig)
+	.catch(err => console.error(err))
+
+wails.Events.On("overlay-config-update", (ev) => {
+	applyConfig(ev.data[0])
 });
 
 document.addEventListener('contextmenu', async () => {
@@ -23,6 +87,8 @@ let backgroundEffectTimeout;
 
 createEffect(() => {
 	muted()
+	blocked()
+	speaking()
 	background.style = '--body-background-alpha: 1'
 	
 	if (backgroundEffectTimeout) {
@@ -64,7 +130,44 @@ function MuteButton() {
 	)
 }
 
+function LevelMeter() {
+	return (
+		<div class={`level ${muted() ? 'muted' : ''}`}>
+			<div class="bar" style={`width: ${Math.round(level() * 100)}%`}></div>
+		</div>
+	)
+}
+
+function BlockedLabel() {
+	createEffect(async () => {
+		blocked()
+		await resizeWindow()
+	})
+
+	return (
+		<Show when={blocked()}>
+			<div class="blocked">{blocked()}</div>
+		</Show>
+	)
+}
+
+function ReconnectLabel() {
+	createEffect(async () => {
+		reconnect()
+		await resizeWindow()
+	})
+
+	return (
+		<Show when={reconnect()}>
+			<div class="reconnect">{reconnect().Failed ? 'reconnect failed' : 'reconnecting'}</div>
+		</Show>
+	)
+}
+
 render(() => <MuteButton />, document.querySelector('[mute-button]'))
+render(() => <LevelMeter />, document.querySelector('[level-meter]'))
+render(() => <BlockedLabel />, document.querySelector('[mute-blocked]'))
+render(() => <ReconnectLabel />, document.querySelector('[reconnect-status]'))
 
 async function resizeWindow() {
 	const width = document.body.offsetWidth;
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var headlessFlag = flag.Bool("headless", false, "run only the audio logic, the hotkey and the automation, without windows or tray")
+
+var ErrHeadless = errors.New("not available in headless mode")
+
+var (
+	headlessQuit     = make(chan struct{})
+	headlessQuitOnce sync.Once
+)
+
+// runHeadless blocks until the process is interrupted or asked to quit
+// by another instance
+func runHeadless() {
+	slog.Info("running headless")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case s := <-sig:
+		slog.Info("headless stop requested", "signal", s.String())
+	case <-headlessQuit:
+		slog.Info("headless stop requested", "signal", "quit action")
+	}
+}
+
+// quit stops the app, either the Wails one or the headless loop
+func quit() {
+	if app != nil {
+		app.Quit()
+		return
+	}
+
+	headlessQuitOnce.Do(func() {
+		close(headlessQuit)
+	})
+}
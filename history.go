@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type HistoryKind string
+
+const (
+	HistoryDeviceAdded          HistoryKind = "device-added"
+	HistoryDeviceRemoved        HistoryKind = "device-removed"
+	HistoryDeviceStateChanged   HistoryKind = "device-state-changed"
+	HistoryDefaultDeviceChanged HistoryKind = "default-device-changed"
+	HistoryMuteChanged          HistoryKind = "mute-changed"
+	HistoryVolumeChanged        HistoryKind = "volume-changed"
+	HistoryHotkeyPressed        HistoryKind = "hotkey-pressed"
+	HistorySelectionChanged     HistoryKind = "selection-changed"
+	HistorySpeakingWhileMuted   HistoryKind = "speaking-while-muted"
+)
+
+type HistoryEvent struct {
+	Time     time.Time
+	Kind     HistoryKind
+	DeviceID string       `json:",omitempty"`
+	Origin   ChangeOrigin `json:",omitempty"`
+	Muted    *bool        `json:",omitempty"`
+	Volume   *float32     `json:",omitempty"`
+	Detail   string       `json:",omitempty"`
+}
+
+const (
+	HistoryFormatJSONL = "jsonl"
+	HistoryFormatCSV   = "csv"
+)
+
+const (
+	historyMaxFileSize = 1 << 20
+	historyMaxFiles    = 5
+)
+
+var ErrHistoryFormat = errors.New("unknown history format")
+
+// History is an append-only journal of HistoryEvents stored as JSON Lines.
+// When the current file grows over historyMaxFileSize it is rotated, keeping
+// at most historyMaxFiles files (the current one included)
+type History struct {
+	file *rotatingFile
+	m    sync.Mutex
+}
+
+func openHistory(path string) (*History, error) {
+	file, err := openRotatingFile(path, historyMaxFileSize, historyMaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("history %w", err)
+	}
+
+	return &History{file: file}, nil
+}
+
+func (h *History) Close() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	return h.file.Close()
+}
+
+func (h *History) Append(ev HistoryEvent) error {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("history event encode: %w", err)
+	}
+	line = append(line, '\n')
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	_, err = h.file.Write(line)
+	if err != nil {
+		return fmt.Errorf("history file write: %w", err)
+	}
+
+	return nil
+}
+
+// Read returns, from the oldest to the most recent, the events recorded
+// at or after since. If limit is greater than zero, only the last limit
+// events are returned
+func (h *History) Read(since time.Time, limit int) ([]HistoryEvent, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var events []HistoryEvent
+	for _, path := range h.file.paths() {
+		err := h.readFile(path, since, &events)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, nil
+}
+
+func (h *History) readFile(path string, since time.Time, events *[]HistoryEvent) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("history file open: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev HistoryEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// A partially written line is not a reason to lose the whole history
+			continue
+		}
+
+		if ev.Time.Before(since) {
+			continue
+		}
+		*events = append(*events, ev)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("history file read: %w", err)
+	}
+	return nil
+}
+
+func (h *History) Export(w io.Writer, format string, since time.Time) error {
+	events, err := h.Read(since, 0)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case HistoryFormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return fmt.Errorf("history export: %w", err)
+			}
+		}
+		return nil
+	case HistoryFormatCSV:
+		return exportHistoryCSV(w, events)
+	default:
+		return fmt.Errorf("%w: %s", ErrHistoryFormat, format)
+	}
+}
+
+func exportHistoryCSV(w io.Writer, events []HistoryEvent) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"Time", "Kind", "DeviceID", "Origin", "Muted", "Volume", "Detail"})
+	if err != nil {
+		return fmt.Errorf("history export: %w", err)
+	}
+
+	for _, ev := range events {
+		var muted, volume string
+		if ev.Muted != nil {
+			muted = strconv.FormatBool(*ev.Muted)
+		}
+		if ev.Volume != nil {
+			volume = strconv.FormatFloat(float64(*ev.Volume), 'f', 2, 32)
+		}
+
+		err := cw.Write([]string{
+			ev.Time.Format(time.RFC3339Nano),
+			string(ev.Kind),
+			ev.DeviceID,
+			string(ev.Origin),
+			muted,
+			volume,
+			ev.Detail,
+		})
+		if err != nil {
+			return fmt.Errorf("history export: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("history export: %w", err)
+	}
+	return nil
+}
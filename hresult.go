@@ -0,0 +1,11 @@
+package main
+
+import "github.com/nixpare/AudioSwitch/hresult"
+
+// HRESULT is decoded by the hresult package, which does not depend on cgo
+type HRESULT = hresult.HRESULT
+
+var (
+	ErrDeviceGone   = hresult.ErrDeviceGone
+	ErrAccessDenied = hresult.ErrAccessDenied
+)
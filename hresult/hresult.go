@@ -0,0 +1,197 @@
+// Package hresult decodes the HRESULT status codes returned by COM and Core Audio.
+// It does not depend on cgo, so it can be built and tested on every platform
+package hresult
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// HRESULT is the status code returned by COM and Core Audio calls.
+// Failure codes can be used directly as errors and matched with errors.Is
+// against ErrDeviceGone, ErrAccessDenied and ErrDeviceNotFound
+type HRESULT uint32
+
+const (
+	FacilityNull    = 0
+	FacilityRPC     = 1
+	FacilityITF     = 4
+	FacilityWin32   = 7
+	FacilityAudClnt = 0x889
+)
+
+const (
+	S_OK    HRESULT = 0x00000000
+	S_FALSE HRESULT = 0x00000001
+
+	E_NOTIMPL      HRESULT = 0x80004001
+	E_NOINTERFACE  HRESULT = 0x80004002
+	E_POINTER      HRESULT = 0x80004003
+	E_ABORT        HRESULT = 0x80004004
+	E_FAIL         HRESULT = 0x80004005
+	E_UNEXPECTED   HRESULT = 0x8000FFFF
+	E_ACCESSDENIED HRESULT = 0x80070005
+	E_HANDLE       HRESULT = 0x80070006
+	E_OUTOFMEMORY  HRESULT = 0x8007000E
+	E_INVALIDARG   HRESULT = 0x80070057
+	E_NOTFOUND     HRESULT = 0x80070490
+
+	HRESULT_FILE_NOT_FOUND       HRESULT = 0x80070002
+	HRESULT_DEVICE_NOT_CONNECTED HRESULT = 0x8007048F
+	HRESULT_DEVICE_REMOVED       HRESULT = 0x80070651
+
+	RPC_E_CHANGED_MODE    HRESULT = 0x80010106
+	RPC_E_DISCONNECTED    HRESULT = 0x80010108
+	CLASS_E_NOAGGREGATION HRESULT = 0x80040110
+	REGDB_E_CLASSNOTREG   HRESULT = 0x80040154
+	CO_E_NOTINITIALIZED   HRESULT = 0x800401F0
+
+	AUDCLNT_E_NOT_INITIALIZED              HRESULT = 0x88890001
+	AUDCLNT_E_ALREADY_INITIALIZED          HRESULT = 0x88890002
+	AUDCLNT_E_WRONG_ENDPOINT_TYPE          HRESULT = 0x88890003
+	AUDCLNT_E_DEVICE_INVALIDATED           HRESULT = 0x88890004
+	AUDCLNT_E_NOT_STOPPED                  HRESULT = 0x88890005
+	AUDCLNT_E_BUFFER_TOO_LARGE             HRESULT = 0x88890006
+	AUDCLNT_E_OUT_OF_ORDER                 HRESULT = 0x88890007
+	AUDCLNT_E_UNSUPPORTED_FORMAT           HRESULT = 0x88890008
+	AUDCLNT_E_INVALID_SIZE                 HRESULT = 0x88890009
+	AUDCLNT_E_DEVICE_IN_USE                HRESULT = 0x8889000A
+	AUDCLNT_E_BUFFER_OPERATION_PENDING     HRESULT = 0x8889000B
+	AUDCLNT_E_THREAD_NOT_REGISTERED        HRESULT = 0x8889000C
+	AUDCLNT_E_EXCLUSIVE_MODE_NOT_ALLOWED   HRESULT = 0x8889000E
+	AUDCLNT_E_ENDPOINT_CREATE_FAILED       HRESULT = 0x8889000F
+	AUDCLNT_E_SERVICE_NOT_RUNNING          HRESULT = 0x88890010
+	AUDCLNT_E_EVENTHANDLE_NOT_EXPECTED     HRESULT = 0x88890011
+	AUDCLNT_E_EXCLUSIVE_MODE_ONLY          HRESULT = 0x88890012
+	AUDCLNT_E_BUFDURATION_PERIOD_NOT_EQUAL HRESULT = 0x88890013
+	AUDCLNT_E_EVENTHANDLE_NOT_SET          HRESULT = 0x88890014
+	AUDCLNT_E_INCORRECT_BUFFER_SIZE        HRESULT = 0x88890015
+	AUDCLNT_E_BUFFER_SIZE_ERROR            HRESULT = 0x88890016
+	AUDCLNT_E_CPUUSAGE_EXCEEDED            HRESULT = 0x88890017
+	AUDCLNT_E_BUFFER_ERROR                 HRESULT = 0x88890018
+	AUDCLNT_E_BUFFER_SIZE_NOT_ALIGNED      HRESULT = 0x88890019
+	AUDCLNT_E_INVALID_DEVICE_PERIOD        HRESULT = 0x88890020
+	AUDCLNT_E_RESOURCES_INVALIDATED        HRESULT = 0x88890026
+
+	AUDCLNT_S_BUFFER_EMPTY HRESULT = 0x08890001
+)
+
+var (
+	ErrDeviceGone     = errors.New("device gone")
+	ErrAccessDenied   = errors.New("access denied")
+	ErrDeviceNotFound = errors.New("device not found")
+)
+
+type hresultInfo struct {
+	name    string
+	message string
+}
+
+var knownHRESULTs = map[HRESULT]hresultInfo{
+	S_OK:    {"S_OK", "operation successful"},
+	S_FALSE: {"S_FALSE", "operation successful but returned false"},
+
+	E_NOTIMPL:      {"E_NOTIMPL", "not implemented"},
+	E_NOINTERFACE:  {"E_NOINTERFACE", "no such interface supported"},
+	E_POINTER:      {"E_POINTER", "invalid pointer"},
+	E_ABORT:        {"E_ABORT", "operation aborted"},
+	E_FAIL:         {"E_FAIL", "unspecified failure"},
+	E_UNEXPECTED:   {"E_UNEXPECTED", "unexpected failure"},
+	E_ACCESSDENIED: {"E_ACCESSDENIED", "general access denied error"},
+	E_HANDLE:       {"E_HANDLE", "invalid handle"},
+	E_OUTOFMEMORY:  {"E_OUTOFMEMORY", "failed to allocate necessary memory"},
+	E_INVALIDARG:   {"E_INVALIDARG", "one or more arguments are not valid"},
+	E_NOTFOUND:     {"E_NOTFOUND", "element not found"},
+
+	HRESULT_FILE_NOT_FOUND:       {"ERROR_FILE_NOT_FOUND", "the system cannot find the file specified"},
+	HRESULT_DEVICE_NOT_CONNECTED: {"ERROR_DEVICE_NOT_CONNECTED", "the device is not connected"},
+	HRESULT_DEVICE_REMOVED:       {"ERROR_DEVICE_REMOVED", "the device has been removed"},
+
+	RPC_E_CHANGED_MODE:    {"RPC_E_CHANGED_MODE", "cannot change thread mode after it is set"},
+	RPC_E_DISCONNECTED:    {"RPC_E_DISCONNECTED", "the object invoked has disconnected from its clients"},
+	CLASS_E_NOAGGREGATION: {"CLASS_E_NOAGGREGATION", "class does not support aggregation"},
+	REGDB_E_CLASSNOTREG:   {"REGDB_E_CLASSNOTREG", "class not registered"},
+	CO_E_NOTINITIALIZED:   {"CO_E_NOTINITIALIZED", "CoInitialize has not been called"},
+
+	AUDCLNT_E_NOT_INITIALIZED:              {"AUDCLNT_E_NOT_INITIALIZED", "the audio stream has not been successfully initialized"},
+	AUDCLNT_E_ALREADY_INITIALIZED:          {"AUDCLNT_E_ALREADY_INITIALIZED", "the audio stream has already been initialized"},
+	AUDCLNT_E_WRONG_ENDPOINT_TYPE:          {"AUDCLNT_E_WRONG_ENDPOINT_TYPE", "the endpoint is of the wrong data flow type"},
+	AUDCLNT_E_DEVICE_INVALIDATED:           {"AUDCLNT_E_DEVICE_INVALIDATED", "the audio endpoint device has been unplugged, reconfigured, disabled or removed"},
+	AUDCLNT_E_NOT_STOPPED:                  {"AUDCLNT_E_NOT_STOPPED", "the audio stream was not stopped at the time of the call"},
+	AUDCLNT_E_BUFFER_TOO_LARGE:             {"AUDCLNT_E_BUFFER_TOO_LARGE", "the requested buffer size is too large"},
+	AUDCLNT_E_OUT_OF_ORDER:                 {"AUDCLNT_E_OUT_OF_ORDER", "a previous GetBuffer call is still in effect"},
+	AUDCLNT_E_UNSUPPORTED_FORMAT:           {"AUDCLNT_E_UNSUPPORTED_FORMAT", "the requested sound format is not supported"},
+	AUDCLNT_E_INVALID_SIZE:                 {"AUDCLNT_E_INVALID_SIZE", "the requested number of frames is not valid"},
+	AUDCLNT_E_DEVICE_IN_USE:                {"AUDCLNT_E_DEVICE_IN_USE", "the endpoint device is already in use"},
+	AUDCLNT_E_BUFFER_OPERATION_PENDING:     {"AUDCLNT_E_BUFFER_OPERATION_PENDING", "the buffer cannot be accessed because a stream reset is in progress"},
+	AUDCLNT_E_THREAD_NOT_REGISTERED:        {"AUDCLNT_E_THREAD_NOT_REGISTERED", "the thread is not registered"},
+	AUDCLNT_E_EXCLUSIVE_MODE_NOT_ALLOWED:   {"AUDCLNT_E_EXCLUSIVE_MODE_NOT_ALLOWED", "exclusive mode is disabled on the device"},
+	AUDCLNT_E_ENDPOINT_CREATE_FAILED:       {"AUDCLNT_E_ENDPOINT_CREATE_FAILED", "the endpoint could not be created"},
+	AUDCLNT_E_SERVICE_NOT_RUNNING:          {"AUDCLNT_E_SERVICE_NOT_RUNNING", "the Windows audio service is not running"},
+	AUDCLNT_E_EVENTHANDLE_NOT_EXPECTED:     {"AUDCLNT_E_EVENTHANDLE_NOT_EXPECTED", "the stream is not initialized for event-driven buffering"},
+	AUDCLNT_E_EXCLUSIVE_MODE_ONLY:          {"AUDCLNT_E_EXCLUSIVE_MODE_ONLY", "the device supports only exclusive mode"},
+	AUDCLNT_E_BUFDURATION_PERIOD_NOT_EQUAL: {"AUDCLNT_E_BUFDURATION_PERIOD_NOT_EQUAL", "buffer duration and periodicity are not equal"},
+	AUDCLNT_E_EVENTHANDLE_NOT_SET:          {"AUDCLNT_E_EVENTHANDLE_NOT_SET", "the event handle has not been set"},
+	AUDCLNT_E_INCORRECT_BUFFER_SIZE:        {"AUDCLNT_E_INCORRECT_BUFFER_SIZE", "the buffer size is incorrect"},
+	AUDCLNT_E_BUFFER_SIZE_ERROR:            {"AUDCLNT_E_BUFFER_SIZE_ERROR", "the buffer duration is out of range"},
+	AUDCLNT_E_CPUUSAGE_EXCEEDED:            {"AUDCLNT_E_CPUUSAGE_EXCEEDED", "the audio processing exceeded the maximum CPU usage"},
+	AUDCLNT_E_BUFFER_ERROR:                 {"AUDCLNT_E_BUFFER_ERROR", "the data buffer could not be retrieved"},
+	AUDCLNT_E_BUFFER_SIZE_NOT_ALIGNED:      {"AUDCLNT_E_BUFFER_SIZE_NOT_ALIGNED", "the buffer size is not aligned"},
+	AUDCLNT_E_INVALID_DEVICE_PERIOD:        {"AUDCLNT_E_INVALID_DEVICE_PERIOD", "the device period is not valid"},
+	AUDCLNT_E_RESOURCES_INVALIDATED:        {"AUDCLNT_E_RESOURCES_INVALIDATED", "the audio resources have been invalidated"},
+
+	AUDCLNT_S_BUFFER_EMPTY: {"AUDCLNT_S_BUFFER_EMPTY", "the capture buffer is empty"},
+}
+
+func (hr HRESULT) Failed() bool {
+	return int32(hr) < 0
+}
+
+func (hr HRESULT) Facility() uint16 {
+	return uint16(uint32(hr)>>16) & 0x1FFF
+}
+
+func (hr HRESULT) Code() uint16 {
+	return uint16(hr)
+}
+
+// Name returns the symbolic name of a known code, or an empty string
+func (hr HRESULT) Name() string {
+	return knownHRESULTs[hr].name
+}
+
+func (hr HRESULT) String() string {
+	return fmt.Sprintf("0x%08X", uint32(hr))
+}
+
+func (hr HRESULT) LogValue() slog.Value {
+	if name := hr.Name(); name != "" {
+		return slog.StringValue(hr.String() + " " + name)
+	}
+	return slog.StringValue(hr.String())
+}
+
+func (hr HRESULT) Error() string {
+	info, ok := knownHRESULTs[hr]
+	if !ok {
+		return fmt.Sprintf("HRESULT %s (facility 0x%x, code 0x%x)", hr.String(), hr.Facility(), hr.Code())
+	}
+	return fmt.Sprintf("%s (%s): %s", info.name, hr.String(), info.message)
+}
+
+func (hr HRESULT) Is(target error) bool {
+	switch target {
+	case ErrDeviceGone:
+		switch hr {
+		case AUDCLNT_E_DEVICE_INVALIDATED, AUDCLNT_E_RESOURCES_INVALIDATED,
+			HRESULT_DEVICE_NOT_CONNECTED, HRESULT_DEVICE_REMOVED, RPC_E_DISCONNECTED:
+			return true
+		}
+	case ErrAccessDenied:
+		return hr == E_ACCESSDENIED
+	case ErrDeviceNotFound:
+		return hr == E_NOTFOUND || hr == HRESULT_FILE_NOT_FOUND
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package hresult
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestKnownCodes(t *testing.T) {
+	tests := []struct {
+		hr    HRESULT
+		name  string
+		error string
+	}{
+		{S_OK, "S_OK", "S_OK (0x00000000): operation successful"},
+		{E_FAIL, "E_FAIL", "E_FAIL (0x80004005): unspecified failure"},
+		{E_ACCESSDENIED, "E_ACCESSDENIED", "E_ACCESSDENIED (0x80070005): general access denied error"},
+		{HRESULT_DEVICE_REMOVED, "ERROR_DEVICE_REMOVED", "ERROR_DEVICE_REMOVED (0x80070651): the device has been removed"},
+		{
+			AUDCLNT_E_DEVICE_INVALIDATED, "AUDCLNT_E_DEVICE_INVALIDATED",
+			"AUDCLNT_E_DEVICE_INVALIDATED (0x88890004): the audio endpoint device has been unplugged, reconfigured, disabled or removed",
+		},
+		{AUDCLNT_S_BUFFER_EMPTY, "AUDCLNT_S_BUFFER_EMPTY", "AUDCLNT_S_BUFFER_EMPTY (0x08890001): the capture buffer is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hr.Name(); got != tt.name {
+				t.Errorf("Name() = %q, want %q", got, tt.name)
+			}
+			if got := tt.hr.Error(); got != tt.error {
+				t.Errorf("Error() = %q, want %q", got, tt.error)
+			}
+		})
+	}
+}
+
+func TestEveryKnownCodeIsNamed(t *testing.T) {
+	for hr, info := range knownHRESULTs {
+		if info.name == "" || info.message == "" {
+			t.Errorf("%s has no name or message", hr)
+		}
+	}
+}
+
+func TestFacilityAndCode(t *testing.T) {
+	tests := []struct {
+		hr       HRESULT
+		failed   bool
+		facility uint16
+		code     uint16
+	}{
+		{S_OK, false, FacilityNull, 0},
+		{S_FALSE, false, FacilityNull, 1},
+		{E_NOTIMPL, true, FacilityNull, 0x4001},
+		{RPC_E_CHANGED_MODE, true, FacilityRPC, 0x0106},
+		{REGDB_E_CLASSNOTREG, true, FacilityITF, 0x0154},
+		{E_INVALIDARG, true, FacilityWin32, 0x0057},
+		{E_NOTFOUND, true, FacilityWin32, 0x0490},
+		{AUDCLNT_E_RESOURCES_INVALIDATED, true, FacilityAudClnt, 0x0026},
+		{AUDCLNT_S_BUFFER_EMPTY, false, FacilityAudClnt, 0x0001},
+		// The customer and reserved bits are not part of the facility
+		{0xE0070005, true, FacilityWin32, 0x0005},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hr.String(), func(t *testing.T) {
+			if got := tt.hr.Failed(); got != tt.failed {
+				t.Errorf("Failed() = %v, want %v", got, tt.failed)
+			}
+			if got := tt.hr.Facility(); got != tt.facility {
+				t.Errorf("Facility() = 0x%x, want 0x%x", got, tt.facility)
+			}
+			if got := tt.hr.Code(); got != tt.code {
+				t.Errorf("Code() = 0x%x, want 0x%x", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestUnknownCodes(t *testing.T) {
+	tests := []struct {
+		hr    HRESULT
+		error string
+	}{
+		{0x80070020, "HRESULT 0x80070020 (facility 0x7, code 0x20)"},
+		{0x88890099, "HRESULT 0x88890099 (facility 0x889, code 0x99)"},
+		{0x00000002, "HRESULT 0x00000002 (facility 0x0, code 0x2)"},
+		{0xFFFFFFFF, "HRESULT 0xFFFFFFFF (facility 0x1fff, code 0xffff)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hr.String(), func(t *testing.T) {
+			if got := tt.hr.Name(); got != "" {
+				t.Errorf("Name() = %q, want none", got)
+			}
+			if got := tt.hr.Error(); got != tt.error {
+				t.Errorf("Error() = %q, want %q", got, tt.error)
+			}
+		})
+	}
+}
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		hr     HRESULT
+		target error
+		want   bool
+	}{
+		{AUDCLNT_E_DEVICE_INVALIDATED, ErrDeviceGone, true},
+		{AUDCLNT_E_RESOURCES_INVALIDATED, ErrDeviceGone, true},
+		{HRESULT_DEVICE_NOT_CONNECTED, ErrDeviceGone, true},
+		{HRESULT_DEVICE_REMOVED, ErrDeviceGone, true},
+		{RPC_E_DISCONNECTED, ErrDeviceGone, true},
+		{E_FAIL, ErrDeviceGone, false},
+		{E_ACCESSDENIED, ErrAccessDenied, true},
+		{E_ACCESSDENIED, ErrDeviceGone, false},
+		{E_NOTFOUND, ErrDeviceNotFound, true},
+		{HRESULT_FILE_NOT_FOUND, ErrDeviceNotFound, true},
+		{E_INVALIDARG, ErrDeviceNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%v", tt.hr, tt.target), func(t *testing.T) {
+			// Wrapped like the errors returned by the device functions
+			err := fmt.Errorf("device x: %w", tt.hr)
+			if got := errors.Is(err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.hr, tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	instanceLockFile = "instance.lock"
+	instanceAddrFile = "instance.addr"
+
+	// instanceForwardTimeout is how long a second instance waits for
+	// the running one to accept its request
+	instanceForwardTimeout = 5 * time.Second
+)
+
+var newInstanceFlag = flag.Bool("new-instance", false, "start even if another instance is already running (development only)")
+
+var (
+	ErrInstanceRunning = errors.New("another instance is running")
+	ErrInstanceAction  = errors.New("unknown instance action")
+)
+
+// Instance actions that can be passed as arguments and are forwarded
+// to the running instance
+const (
+	ActionShow    = "show"
+	ActionOverlay = "overlay"
+	ActionToggle  = "toggle"
+	ActionQuit    = "quit"
+)
+
+// instanceLock keeps the save directory owned by a single process and
+// receives the requests of the other launches
+type instanceLock struct {
+	file     *os.File
+	listener net.Listener
+	token    string
+}
+
+type instanceRequest struct {
+	Token string
+	Args  []string
+}
+
+type instanceResponse struct {
+	Error string
+}
+
+// acquireInstanceLock takes the lock in the save directory, or returns
+// ErrInstanceRunning if another process holds it
+func acquireInstanceLock() (*instanceLock, error) {
+	f, err := os.OpenFile(filepath.Join(saveDir, instanceLockFile), os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return nil, fmt.Errorf("instance lock open: %w", err)
+	}
+
+	// The lock is released by the system if the process dies
+	err = windows.LockFileEx(
+		windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, 1, 0, new(windows.Overlapped),
+	)
+	if err != nil {
+		f.Close()
+		if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return nil, ErrInstanceRunning
+		}
+		return nil, fmt.Errorf("instance lock: %w", err)
+	}
+
+	return &instanceLock{file: f}, nil
+}
+
+// listen accepts the requests forwarded by the other launches, publishing
+// the address and a token to authenticate them in the save directory
+func (l *instanceLock) listen() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("instance listen: %w", err)
+	}
+
+	token := make([]byte, 16)
+	_, err = rand.Read(token)
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("instance token: %w", err)
+	}
+	l.token = hex.EncodeToString(token)
+
+	addr := ln.Addr().String() + "\n" + l.token
+	err = os.WriteFile(filepath.Join(saveDir, instanceAddrFile), []byte(addr), 0600)
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("instance address write: %w", err)
+	}
+
+	l.listener = ln
+	go l.serve()
+
+	return nil
+}
+
+func (l *instanceLock) serve() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("instance accept failed", "err", err)
+			}
+			return
+		}
+
+		go l.handle(conn)
+	}
+}
+
+func (l *instanceLock) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(instanceForwardTimeout))
+
+	var req instanceRequest
+	err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req)
+	if err != nil {
+		slog.Warn("instance request decode failed", "err", err)
+		return
+	}
+
+	if req.Token != l.token {
+		slog.Warn("instance request rejected", "reason", "invalid token")
+		return
+	}
+
+	slog.Info("instance request received", "args", req.Args)
+
+	var resp instanceResponse
+	err = runInstanceAction(req.Args)
+	if err != nil {
+		slog.Error("instance action failed", "args", req.Args, "err", err)
+		resp.Error = err.Error()
+	}
+
+	err = json.NewEncoder(conn).Encode(resp)
+	if err != nil {
+		slog.Warn("instance response encode failed", "err", err)
+	}
+}
+
+// Close stops receiving requests and releases the lock
+func (l *instanceLock) Close() error {
+	if l.listener != nil {
+		l.listener.Close()
+		os.Remove(filepath.Join(saveDir, instanceAddrFile))
+	}
+
+	windows.UnlockFileEx(windows.Handle(l.file.Fd()), 0, 1, 0, new(windows.Overlapped))
+	return l.file.Close()
+}
+
+// forwardToInstance sends the arguments to the running instance, retrying
+// while it is still starting up
+func forwardToInstance(args []string) error {
+	deadline := time.Now().Add(instanceForwardTimeout)
+
+	for {
+		err := tryForwardToInstance(args)
+		if err == nil || errors.Is(err, ErrInstanceAction) || time.Now().After(deadline) {
+			return err
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func tryForwardToInstance(args []string) error {
+	data, err := os.ReadFile(filepath.Join(saveDir, instanceAddrFile))
+	if err != nil {
+		return fmt.Errorf("instance address read: %w", err)
+	}
+
+	addr, token, ok := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	if !ok {
+		return errors.New("instance address malformed")
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return fmt.Errorf("instance dial: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(instanceForwardTimeout))
+
+	err = json.NewEncoder(conn).Encode(instanceRequest{Token: token, Args: args})
+	if err != nil {
+		return fmt.Errorf("instance request encode: %w", err)
+	}
+
+	var resp instanceResponse
+	err = json.NewDecoder(conn).Decode(&resp)
+	if err != nil {
+		return fmt.Errorf("instance response decode: %w", err)
+	}
+
+	if resp.Error != "" {
+		return fmt.Errorf("%w: %s", ErrInstanceAction, resp.Error)
+	}
+	return nil
+}
+
+// validateInstanceArgs checks the actions before starting or forwarding them
+func validateInstanceArgs(args []string) error {
+	for _, action := range args {
+		switch action {
+		case ActionShow, ActionOverlay, ActionToggle, ActionQuit:
+		default:
+			return fmt.Errorf("%w: %s", ErrInstanceAction, action)
+		}
+	}
+
+	return nil
+}
+
+// runInstanceAction executes the actions passed on the command line,
+// showing the dashboard if there are none
+func runInstanceAction(args []string) error {
+	if len(args) == 0 {
+		if *headlessFlag {
+			return nil
+		}
+		args = []string{ActionShow}
+	}
+
+	err := validateInstanceArgs(args)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range args {
+		switch action {
+		case ActionShow, ActionOverlay:
+			if *headlessFlag {
+				return fmt.Errorf("%s: %w", action, ErrHeadless)
+			}
+			if action == ActionShow {
+				windowService.CreateWindow()
+			} else {
+				windowService.CreateOverlay()
+			}
+		case ActionToggle:
+			err = audioService.ToggleSelected()
+			if err != nil {
+				return err
+			}
+		case ActionQuit:
+			quit()
+		}
+	}
+
+	return nil
+}
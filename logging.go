@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	logMaxFileSize = 4 << 20
+	logMaxFiles    = 3
+)
+
+var (
+	logLevelFlag = flag.String("log-level", "info", "minimum level of the logs (debug, info, warn, error)")
+	logJSONFlag  = flag.Bool("log-json", false, "write the logs as JSON objects")
+)
+
+// logLevel is shared by every handler so it can be changed at runtime
+var logLevel = new(slog.LevelVar)
+
+func initLogs() error {
+	err := logLevel.UnmarshalText([]byte(*logLevelFlag))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogFile, err)
+	}
+
+	var out io.Writer = os.Stderr
+	if ProductionBuild {
+		f, err := openRotatingFile(filepath.Join(saveDir, "app.log"), logMaxFileSize, logMaxFiles)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLogFile, err)
+		}
+		// avoid defer f.Close()
+
+		// Everything that is not written through slog (panics, cgo and
+		// the webview) must follow the log file across rotations
+		f.onRotate = redirectStdHandles
+		err = redirectStdHandles(f.File())
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLogFile, err)
+		}
+
+		_, err = f.Write([]byte("\n------------------------------------------------\n\n"))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrLogFile, err)
+		}
+
+		out = f
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	if *logJSONFlag {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func redirectStdHandles(f *os.File) error {
+	os.Stdout, os.Stderr = f, f
+
+	err := windows.SetStdHandle(windows.STD_OUTPUT_HANDLE, windows.Handle(f.Fd()))
+	if err != nil {
+		return err
+	}
+
+	return windows.SetStdHandle(windows.STD_ERROR_HANDLE, windows.Handle(f.Fd()))
+}
+
+// LogService exposes the logging configuration to the frontend
+type LogService struct{}
+
+func (LogService) GetLogLevel() string {
+	return logLevel.Level().String()
+}
+
+func (LogService) SetLogLevel(level string) error {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(level))
+	if err != nil {
+		return err
+	}
+
+	logLevel.Set(l)
+	slog.Info("log level changed", "level", l)
+	return nil
+}
@@ -7,14 +7,15 @@ import "C"
 import (
 	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
-	"golang.org/x/sys/windows"
 )
 
 //go:embed frontend/dist
@@ -24,12 +25,17 @@ var (
 	app           *application.App
 	audioService  *AudioService
 	windowService *WindowService
+	instance      *instanceLock
+
+	// bus is where the backend events are emitted, whether the app is running or not
+	bus EventBus
 )
 
 var (
 	saveDir            string
 	windowSaveFilePath string
 	audioSaveFilePath  string
+	historyFilePath    string
 )
 
 var (
@@ -37,12 +43,36 @@ var (
 	ErrLogFile     = errors.New("log file creation")
 )
 
-func init() {
+// setup parses the flags, takes the instance lock and starts the logs.
+// It runs from main and not from init, so that the package can be tested
+func setup() {
+	flag.Parse()
+
 	err := initSaveDirAndPaths()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	err = validateInstanceArgs(flag.Args())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if !*newInstanceFlag {
+		// Checked before the logs, as they are owned by the running instance too
+		instance, err = acquireInstanceLock()
+		if errors.Is(err, ErrInstanceRunning) {
+			err = forwardToInstance(flag.Args())
+			if err != nil {
+				log.Fatalln(err)
+			}
+			os.Exit(0)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
 	err = initLogs()
 	if err != nil {
 		log.Fatalln(err)
@@ -50,52 +80,99 @@ func init() {
 }
 
 func main() {
+	setup()
+
 	var exitCode int
 	defer os.Exit(exitCode)
 
-	log.Println("Starting AudioSwitch")
-	defer log.Println("Stopping AudioSwitch")
+	slog.Info("starting AudioSwitch")
+	defer slog.Info("stopping AudioSwitch")
 
-	audioService = newAudioService()
+	// The services are created before the application, which
+	// is then given to the bus if not running headless
+	wailsBus := &wailsEventBus{}
+	if *headlessFlag {
+		bus = newLocalEventBus()
+	} else {
+		bus = wailsBus
+	}
+
+	audioService = newAudioService(bus, audioSaveFilePath, historyFilePath)
 
 	var err error
 	windowService, err = newWindowService()
 	if err != nil {
-		log.Fatalln(err)
+		slog.Error("window service init failed", "err", err)
+		os.Exit(1)
 	}
 	defer func() {
 		err := windowService.Close()
 		if err != nil {
-			log.Println(err)
+			slog.Error("window service close failed", "err", err)
 		}
 	}()
 
-	app = application.New(application.Options{
-		Name:        "Audio Switch",
-		Description: "A switch for toggling audio devices",
-		Assets: application.AssetOptions{
-			Handler: application.AssetFileServerFS(assets),
-		},
-		Services: []application.Service{
-			application.NewService(audioService),
-			application.NewService(windowService),
-		},
-		ErrorHandler: func(err error) {
-			log.Println(err)
-		},
-	})
+	if !*headlessFlag {
+		app = application.New(application.Options{
+			Name:        "Audio Switch",
+			Description: "A switch for toggling audio devices",
+			Assets: application.AssetOptions{
+				Handler: application.AssetFileServerFS(assets),
+			},
+			Services: []application.Service{
+				application.NewService(audioService),
+				application.NewService(windowService),
+				application.NewService(&LogService{}),
+			},
+			ErrorHandler: func(err error) {
+				slog.Error("app error", "err", err)
+			},
+		})
+		wailsBus.app = app
+	}
 
 	err = audioService.Start()
 	if err != nil {
-		log.Fatalln(err)
+		slog.Error("audio service start failed", "err", err)
+		os.Exit(1)
 	}
 	defer func() {
 		err := audioService.Stop()
 		if err != nil {
-			log.Println(err)
+			slog.Error("audio service stop failed", "err", err)
 		}
 	}()
 
+	if instance != nil {
+		err = instance.listen()
+		if err != nil {
+			slog.Error("instance listen failed", "err", err)
+		}
+		defer func() {
+			err := instance.Close()
+			if err != nil {
+				slog.Error("instance lock release failed", "err", err)
+			}
+		}()
+	}
+
+	if *headlessFlag {
+		if len(flag.Args()) > 0 {
+			err := runInstanceAction(flag.Args())
+			if err != nil {
+				slog.Error("startup action failed", "args", flag.Args(), "err", err)
+			}
+		}
+
+		runHeadless()
+		return
+	}
+
+	_, err = newTray()
+	if err != nil {
+		slog.Error("tray init failed", "err", err)
+	}
+
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
@@ -105,11 +182,21 @@ func main() {
 		// Fake main thread after app.Run()
 		
 		windowService.CreateOverlay()
-		windowService.CreateWindow()
+		if !windowService.StartupConfig.StartMinimised {
+			windowService.CreateWindow()
+		}
+		go windowService.watchScreens()
+
+		if len(flag.Args()) > 0 {
+			err := runInstanceAction(flag.Args())
+			if err != nil {
+				slog.Error("startup action failed", "args", flag.Args(), "err", err)
+			}
+		}
 	}()
 
 	if err = app.Run(); err != nil {
-		log.Printf("app fatal error: %v\n", err)
+		slog.Error("app fatal error", "err", err)
 		exitCode = 1
 	}
 }
@@ -133,6 +220,7 @@ func initSaveDirAndPaths() error {
 	saveDir = dir
 	audioSaveFilePath = filepath.Join(dir, "audio_save.json")
 	windowSaveFilePath = filepath.Join(dir, "window_save.json")
+	historyFilePath = filepath.Join(dir, "history.jsonl")
 
 	return nil
 }
@@ -158,35 +246,3 @@ func buildSaveDirectory(base string, dir string) (string, error) {
 
 	return dir, nil
 }
-
-func initLogs() error {
-	if !ProductionBuild {
-		return nil
-	}
-
-	f, err := os.OpenFile(filepath.Join(saveDir, "app.log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrLogFile, err)
-	}
-	// avoid defer f.Close()
-
-	os.Stdout, os.Stderr = f, f
-	log.SetOutput(f)
-
-	err = windows.SetStdHandle(windows.STD_OUTPUT_HANDLE, windows.Handle(f.Fd()))
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrLogFile, err)
-	}
-
-	err = windows.SetStdHandle(windows.STD_ERROR_HANDLE, windows.Handle(f.Fd()))
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrLogFile, err)
-	}
-
-	_, err = f.WriteString("\n------------------------------------------------\n\n")
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrLogFile, err)
-	}
-
-	return nil
-}
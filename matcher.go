@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// builtinContainerID is shared by every device integrated in the computer,
+// so it does not tell them apart
+const builtinContainerID = "{00000000-0000-0000-FFFF-FFFFFFFFFFFF}"
+
+// Weights of the attributes of a fingerprint: a match needs at least
+// minMatchScore, i.e. two of them. The interface name alone is not
+// enough, as every endpoint of the same codec shares it. The container ID
+// stands in for the hardware IDs, which the endpoint does not expose, but
+// devices without a serial number get a new one on every USB port
+const (
+	interfaceNameScore = 2
+	containerIDScore   = 2
+	nameScore          = 1
+	minMatchScore      = 3
+)
+
+// DeviceReboundEvent is emitted when a saved device is bound to a new endpoint ID
+type DeviceReboundEvent struct {
+	OldID string
+	NewID string
+}
+
+// DeviceAmbiguousEvent is emitted when more than one device
+// matches a saved one equally well, in which case none is chosen
+type DeviceAmbiguousEvent struct {
+	DeviceID   string
+	Candidates []string
+}
+
+// matchScore tells how much the device looks like the saved one,
+// based on the attributes that survive a change of the endpoint ID
+func matchScore(saved, device DeviceState) int {
+	var score int
+	if saved.InterfaceName != "" && saved.InterfaceName == device.InterfaceName {
+		score += interfaceNameScore
+	}
+	if saved.ContainerID != "" && saved.ContainerID != builtinContainerID && saved.ContainerID == device.ContainerID {
+		score += containerIDScore
+	}
+	if saved.Name != "" && saved.Name == device.Name {
+		score += nameScore
+	}
+	return score
+}
+
+// matchDevice returns the candidate that best matches the saved device.
+// If more than one candidate has the best score, they are all returned
+// as ambiguous
+func matchDevice(saved DeviceState, candidates []*Device) (*Device, []string) {
+	var best []*Device
+	bestScore := minMatchScore
+
+	for _, device := range candidates {
+		score := matchScore(saved, device.DeviceState)
+		switch {
+		case score > bestScore:
+			best, bestScore = []*Device{device}, score
+		case score == bestScore:
+			best = append(best, device)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return nil, nil
+	case 1:
+		return best[0], nil
+	default:
+		ids := make([]string, 0, len(best))
+		for _, device := range best {
+			ids = append(ids, device.ID)
+		}
+		slices.Sort(ids)
+		return nil, ids
+	}
+}
+
+// rebindPrefs binds every preference whose endpoint is gone to the active
+// device that best matches it, if any. Endpoints that are still enumerated,
+// even if unplugged or disabled, keep their preference. The selected
+// device follows its preference
+func (s *AudioService) rebindPrefs() error {
+	var candidates []*Device
+	for id, device := range s.Devices {
+		if _, ok := s.Prefs[id]; !ok && device.isActive() {
+			candidates = append(candidates, device)
+		}
+	}
+
+	var rebound bool
+	for id, pref := range s.Prefs {
+		if _, ok := s.Devices[id]; ok {
+			continue
+		}
+
+		match, ambiguous := matchDevice(pref.DeviceState, candidates)
+		if len(ambiguous) > 0 {
+			slog.Warn("saved device matches more than one device", "device", id, "name", pref.Name, "candidates", ambiguous)
+			s.publisher.Emit("audio-device-ambiguous", DeviceAmbiguousEvent{DeviceID: id, Candidates: ambiguous})
+			continue
+		}
+		if match == nil {
+			continue
+		}
+
+		slog.Info("saved device rebound", "old", id, "new", match.ID, "name", match.Name)
+		rebound = true
+		delete(s.Prefs, id)
+		s.Prefs[match.ID] = match
+		candidates = slices.DeleteFunc(candidates, func(device *Device) bool {
+			return device == match
+		})
+
+		s.publisher.Emit("audio-device-rebound", DeviceReboundEvent{OldID: id, NewID: match.ID})
+
+		if s.Selected == id {
+			s.Selected = match.ID
+			s.record(HistoryEvent{Kind: HistorySelectionChanged, DeviceID: match.ID, Detail: "rebound from " + id})
+
+			err := s.activateSelected()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if rebound {
+		return s.updateSaveData()
+	}
+	return nil
+}
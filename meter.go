@@ -0,0 +1,249 @@
+package main
+
+/*
+#include "winaudio_wrapper.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// meterInterval is the minimum time between two "audio-level" events
+const meterInterval = 50 * time.Millisecond
+
+// LevelEvent holds the peak level, from 0 to 1, of every active device
+// since the previous event
+type LevelEvent struct {
+	Levels   map[string]float32
+	Selected float32
+}
+
+func (d *Device) startMeter() error {
+	if d.meter != nil {
+		return nil
+	}
+	if d.device == nil {
+		return fmt.Errorf("device %s meter: %w", d.ID, ErrDeviceGone)
+	}
+
+	if hr := C.IMMDevice_ActivateMeter(d.device, &d.meter); hr < 0 {
+		return fmt.Errorf("device %s meter: %w", d.ID, HRESULT(hr))
+	}
+
+	if hr := C.IMMDevice_StartCapture(d.device, &d.meterClient); hr < 0 {
+		d.stopMeter()
+		return fmt.Errorf("device %s meter capture: %w", d.ID, HRESULT(hr))
+	}
+
+	return nil
+}
+
+func (d *Device) stopMeter() {
+	if d.meterClient != nil {
+		C.IAudioClient_StopCapture(d.meterClient)
+		d.meterClient = nil
+	}
+	if d.meter != nil {
+		C.IAudioMeterInformation_Release(d.meter)
+		d.meter = nil
+	}
+}
+
+func (d *Device) getPeak() (float32, error) {
+	if d.meter == nil {
+		return 0, nil
+	}
+
+	var peak C.float
+	if hr := C.IAudioMeterInformation_GetPeakValue(d.meter, &peak); hr < 0 {
+		return 0, fmt.Errorf("device %s peak: %w", d.ID, HRESULT(hr))
+	}
+
+	return float32(peak), nil
+}
+
+// SubscribeLevels starts the "audio-level" events, if not already started.
+// Every call must be paired with one to UnsubscribeLevels: the events stop
+// when no subscriber is left
+func (s *AudioService) SubscribeLevels() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	s.levelSubscribers++
+	s.updateMetering()
+	return nil
+}
+
+func (s *AudioService) UnsubscribeLevels() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.levelSubscribers == 0 {
+		return nil
+	}
+
+	s.levelSubscribers--
+	s.updateMetering()
+	return nil
+}
+
+// SubscribeSelectedLevel is like SubscribeLevels, but only the selected device
+// is metered unless someone subscribed to every level too
+func (s *AudioService) SubscribeSelectedLevel() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	s.selectedLevelSubscribers++
+	s.updateMetering()
+	return nil
+}
+
+func (s *AudioService) UnsubscribeSelectedLevel() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.selectedLevelSubscribers == 0 {
+		return nil
+	}
+
+	s.selectedLevelSubscribers--
+	s.updateMetering()
+	return nil
+}
+
+// updateMetering starts or stops the metering depending on whether someone
+// is subscribed, VOX is enabled or the selected device must be watched for
+// speech while muted. It must be called while holding the lock
+func (s *AudioService) updateMetering() {
+	needed := s.running && (s.levelSubscribers > 0 || s.selectedLevelSubscribers > 0 ||
+		s.VOX.Enabled || (s.SpeechDetect.Enabled && s.Muted))
+
+	switch {
+	case needed && s.meterStop == nil:
+		s.meterStop = make(chan struct{})
+		go s.runMeter(s.meterStop)
+	case !needed:
+		s.stopMetering()
+	}
+}
+
+// stopMetering makes runMeter return, it must be called while holding the lock
+func (s *AudioService) stopMetering() {
+	if s.meterStop == nil {
+		return
+	}
+
+	close(s.meterStop)
+	s.meterStop = nil
+}
+
+func (s *AudioService) runMeter(stop chan struct{}) {
+	ticker := time.NewTicker(meterInterval)
+	defer ticker.Stop()
+
+	defer func() {
+		s.m.Lock()
+		defer s.m.Unlock()
+
+		if s.running {
+			s.com.do(s.stopMeters)
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.m.Lock()
+		if !s.running {
+			s.m.Unlock()
+			return
+		}
+
+		select {
+		case <-stop:
+			// Stopped while waiting for the lock
+			s.m.Unlock()
+			return
+		default:
+		}
+
+		err := s.com.do(s.readLevels)
+		s.m.Unlock()
+
+		if err != nil {
+			slog.Error("level metering failed", "err", err)
+		}
+	}
+}
+
+// readLevels emits the peak level of every active device, starting
+// and stopping the meters as the devices change state. Without
+// subscribers to every level only the selected device is metered
+func (s *AudioService) readLevels() error {
+	ev := LevelEvent{Levels: make(map[string]float32)}
+	var selected LevelSample
+
+	for id, device := range s.Devices {
+		if !device.isActive() || (s.levelSubscribers == 0 && id != s.Selected) {
+			device.stopMeter()
+			continue
+		}
+
+		var sample LevelSample
+		err := device.startMeter()
+		if err == nil {
+			sample, err = LevelSource(device).Sample()
+		}
+
+		if errors.Is(err, ErrDeviceGone) {
+			// The device event will follow
+			device.stopMeter()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		ev.Levels[id] = sample.Level
+		if id == s.Selected {
+			selected = sample
+		}
+	}
+
+	ev.Selected = selected.Level
+	if s.levelSubscribers > 0 || s.selectedLevelSubscribers > 0 {
+		s.publisher.Emit("audio-level", ev)
+	}
+
+	if _, ok := ev.Levels[s.Selected]; !ok {
+		return nil
+	}
+
+	// With VOX the device is muted on purpose while silent
+	if s.VOX.Enabled {
+		return s.feedVOX(selected)
+	}
+	return s.detectSpeech(selected)
+}
+
+func (s *AudioService) stopMeters() error {
+	for _, device := range s.Devices {
+		device.stopMeter()
+	}
+	return nil
+}
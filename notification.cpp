@@ -3,10 +3,11 @@
 class NotificationClient : public IMMNotificationClient {
 private:
 	LONG _cRef;  // Conteggio dei riferimenti per la gestione del ciclo di vita dell'oggetto
+	UINT_PTR _handle;  // Identifica il servizio che riceve le notifiche
 
 public:
 	// Costruttore
-	NotificationClient() : _cRef(1) {}
+	NotificationClient(UINT_PTR handle) : _cRef(1), _handle(handle) {}
 
 	// Implementazione di IUnknown
 	ULONG STDMETHODCALLTYPE AddRef() {
@@ -33,33 +34,34 @@ public:
 
 	// Implementazione dei metodi IMMNotificationClient
 	HRESULT STDMETHODCALLTYPE OnDeviceStateChanged(LPCWSTR pwstrDeviceId, DWORD dwNewState) {
-		return OnDeviceStateChangedCallback(pwstrDeviceId, dwNewState);
+		return OnDeviceStateChangedCallback(_handle, pwstrDeviceId, dwNewState);
 	}
 
 	HRESULT STDMETHODCALLTYPE OnDeviceAdded(LPCWSTR pwstrDeviceId) {
-		return OnDeviceAddedCallback(pwstrDeviceId);
+		return OnDeviceAddedCallback(_handle, pwstrDeviceId);
 	}
 
 	HRESULT STDMETHODCALLTYPE OnDeviceRemoved(LPCWSTR pwstrDeviceId) {
-		return OnDeviceRemovedCallback(pwstrDeviceId);
+		return OnDeviceRemovedCallback(_handle, pwstrDeviceId);
 	}
 
 	HRESULT STDMETHODCALLTYPE OnDefaultDeviceChanged(EDataFlow flow, ERole role, LPCWSTR pwstrDefaultDeviceId) {
-		return OnDefaultDeviceChangedCallback(flow, role, pwstrDefaultDeviceId);
+		return OnDefaultDeviceChangedCallback(_handle, flow, role, pwstrDefaultDeviceId);
 	}
 
 	HRESULT STDMETHODCALLTYPE OnPropertyValueChanged(LPCWSTR pwstrDeviceId, const PROPERTYKEY key) {
-		return OnPropertyValueChangedCallback(pwstrDeviceId, key);
+		return OnPropertyValueChangedCallback(_handle, pwstrDeviceId, key);
 	}
 };
 
 class EndpointVolumeCallback : public IAudioEndpointVolumeCallback {
 private:
 	LONG _cRef;  // Conteggio dei riferimenti per la gestione del ciclo di vita dell'oggetto
+	UINT_PTR _handle;  // Identifica il servizio che riceve le notifiche
 
 public:
 	// Costruttore
-	EndpointVolumeCallback() : _cRef(1) {}
+	EndpointVolumeCallback(UINT_PTR handle) : _cRef(1), _handle(handle) {}
 
 	// Implementazione di IUnknown
 	ULONG STDMETHODCALLTYPE AddRef() {
@@ -86,14 +88,14 @@ public:
 
 	// Implementazione dei metodi IAudioEndpointVolumeCallback
 	HRESULT STDMETHODCALLTYPE OnNotify(PAUDIO_VOLUME_NOTIFICATION_DATA pNotify) {
-		return OnEndpointVolumeChangeNotify(pNotify);
+		return OnEndpointVolumeChangeNotify(_handle, pNotify);
 	}
 };
 
 extern "C" {
 
-	HRESULT RegisterNotificationClient(IMMDeviceEnumerator* deviceEnum, IMMNotificationClient** notifClient) {
-		*notifClient = new NotificationClient();
+	HRESULT RegisterNotificationClient(IMMDeviceEnumerator* deviceEnum, UINT_PTR handle, IMMNotificationClient** notifClient) {
+		*notifClient = new NotificationClient(handle);
 		return deviceEnum->RegisterEndpointNotificationCallback(*notifClient);
 	}
 
@@ -103,8 +105,8 @@ extern "C" {
 		return hr;
 	}
 
-	HRESULT RegisterControlChangeNotify(IAudioEndpointVolume* volume, IAudioEndpointVolumeCallback** volumeCallback) {
-		*volumeCallback = new EndpointVolumeCallback();
+	HRESULT RegisterControlChangeNotify(IAudioEndpointVolume* volume, UINT_PTR handle, IAudioEndpointVolumeCallback** volumeCallback) {
+		*volumeCallback = new EndpointVolumeCallback(handle);
 		return volume->RegisterControlChangeNotify(*volumeCallback);
 	}
 
@@ -6,63 +6,124 @@ package main
 #include "notification.h"
 */
 import "C"
-import (
-	"log"
-)
+import "sync"
 
-//export OnDeviceStateChangedCallback
-func OnDeviceStateChangedCallback(pwstrDeviceId C.LPCWSTR, dwNewState C.DWORD) C.HRESULT {
-	err := audioService.updateFrontend(true)
-	if err != nil {
-		log.Printf("OnDeviceStateChangedCallback error: %v\n", err)
-		return C.E_FAIL
+// notificationHandles maps the handle given to each notification client
+// and volume callback to the event queue of the service that registered it
+var notificationHandles = struct {
+	queues map[uintptr]*eventQueue
+	next   uintptr
+	m      sync.RWMutex
+}{
+	queues: make(map[uintptr]*eventQueue),
+}
+
+func registerNotificationHandle(events *eventQueue) C.UINT_PTR {
+	notificationHandles.m.Lock()
+	defer notificationHandles.m.Unlock()
+
+	// 0 is never used, so a callback without a handle matches nothing
+	notificationHandles.next++
+	handle := notificationHandles.next
+	notificationHandles.queues[handle] = events
+
+	return C.UINT_PTR(handle)
+}
+
+func unregisterNotificationHandle(handle C.UINT_PTR) {
+	notificationHandles.m.Lock()
+	defer notificationHandles.m.Unlock()
+
+	delete(notificationHandles.queues, uintptr(handle))
+}
+
+// dispatchNotification enqueues the event for the service owning the handle,
+// dropping it if the service has been stopped in the meantime
+func dispatchNotification(handle C.UINT_PTR, ev backendEvent) {
+	notificationHandles.m.RLock()
+	events, ok := notificationHandles.queues[uintptr(handle)]
+	notificationHandles.m.RUnlock()
+
+	if ok {
+		events.push(ev)
 	}
+}
+
+// The callbacks below are called by Core Audio on its own threads: they
+// must never block, so they only enqueue the event for the owning service
+
+//export OnDeviceStateChangedCallback
+func OnDeviceStateChangedCallback(handle C.UINT_PTR, pwstrDeviceId C.LPCWSTR, dwNewState C.DWORD) C.HRESULT {
+	dispatchNotification(handle, backendEvent{
+		kind:     deviceStateChangedEvent,
+		deviceID: LPCWSTRToStr(pwstrDeviceId),
+		state:    uint32(dwNewState),
+	})
 	return C.S_OK
 }
 
 //export OnDeviceAddedCallback
-func OnDeviceAddedCallback(pwstrDeviceId C.LPCWSTR) C.HRESULT {
-	err := audioService.updateFrontend(true)
-	if err != nil {
-		log.Printf("OnDeviceAddedCallback error: %v\n", err)
-		return C.E_FAIL
-	}
+func OnDeviceAddedCallback(handle C.UINT_PTR, pwstrDeviceId C.LPCWSTR) C.HRESULT {
+	dispatchNotification(handle, backendEvent{
+		kind:     deviceAddedEvent,
+		deviceID: LPCWSTRToStr(pwstrDeviceId),
+	})
 	return C.S_OK
 }
 
 //export OnDeviceRemovedCallback
-func OnDeviceRemovedCallback(pwstrDeviceId C.LPCWSTR) C.HRESULT {
-	err := audioService.updateFrontend(true)
-	if err != nil {
-		log.Printf("OnDeviceRemovedCallback error: %v\n", err)
-		return C.E_FAIL
-	}
+func OnDeviceRemovedCallback(handle C.UINT_PTR, pwstrDeviceId C.LPCWSTR) C.HRESULT {
+	dispatchNotification(handle, backendEvent{
+		kind:     deviceRemovedEvent,
+		deviceID: LPCWSTRToStr(pwstrDeviceId),
+	})
 	return C.S_OK
 }
 
 //export OnDefaultDeviceChangedCallback
-func OnDefaultDeviceChangedCallback(flow C.EDataFlow, role C.ERole, pwstrDefaultDeviceId C.LPCWSTR) C.HRESULT {
+func OnDefaultDeviceChangedCallback(handle C.UINT_PTR, flow C.EDataFlow, role C.ERole, pwstrDefaultDeviceId C.LPCWSTR) C.HRESULT {
+	var id string
+	if pwstrDefaultDeviceId != nil {
+		id = LPCWSTRToStr(pwstrDefaultDeviceId)
+	}
+
+	dispatchNotification(handle, backendEvent{
+		kind:     defaultDeviceChangedEvent,
+		deviceID: id,
+		flow:     dataFlowString(flow),
+		role:     roleString(role),
+	})
 	return C.S_OK
 }
 
 //export OnPropertyValueChangedCallback
-func OnPropertyValueChangedCallback(pwstrDeviceId C.LPCWSTR, key C.PROPERTYKEY) C.HRESULT {
+func OnPropertyValueChangedCallback(handle C.UINT_PTR, pwstrDeviceId C.LPCWSTR, key C.PROPERTYKEY) C.HRESULT {
+	property := propertyName(key)
+	if property == "" {
+		return C.S_OK
+	}
+
+	dispatchNotification(handle, backendEvent{
+		kind:     propertyChangedEvent,
+		deviceID: LPCWSTRToStr(pwstrDeviceId),
+		property: property,
+	})
 	return C.S_OK
 }
 
 //export OnEndpointVolumeChangeNotify
-func OnEndpointVolumeChangeNotify(pNotify C.PAUDIO_VOLUME_NOTIFICATION_DATA) C.HRESULT {
-	err := audioService.setPrefMute(pNotify.bMuted != 0)
-	if err != nil {
-		log.Printf("OnEndpointVolumeChangeNotify error: %v\n", err)
-		return C.E_FAIL
-	}
-
-	err = audioService.updateFrontend(false)
-	if err != nil {
-		log.Printf("OnEndpointVolumeChangeNotify error: %v\n", err)
-		return C.E_FAIL
+func OnEndpointVolumeChangeNotify(handle C.UINT_PTR, pNotify C.PAUDIO_VOLUME_NOTIFICATION_DATA) C.HRESULT {
+	// Changes made by the app are already reflected in the state
+	origin := eventOrigin(pNotify.guidEventContext)
+	if origin == OriginSelf {
+		return C.S_OK
 	}
 
+	dispatchNotification(handle, backendEvent{
+		kind:   volumeChangedEvent,
+		muted:  pNotify.bMuted != 0,
+		volume: float32(pNotify.fMasterVolume),
+		origin: origin,
+	})
 	return C.S_OK
 }
@@ -13,10 +13,10 @@
 extern "C" {
 #endif
 
-	HRESULT RegisterNotificationClient(IMMDeviceEnumerator* deviceEnum, IMMNotificationClient** notifClient);
+	HRESULT RegisterNotificationClient(IMMDeviceEnumerator* deviceEnum, UINT_PTR handle, IMMNotificationClient** notifClient);
 	HRESULT UnregisterNotificationClient(IMMDeviceEnumerator* deviceEnum, IMMNotificationClient* notifClient);
 
-	HRESULT RegisterControlChangeNotify(IAudioEndpointVolume* volume, IAudioEndpointVolumeCallback** volumeCallback);
+	HRESULT RegisterControlChangeNotify(IAudioEndpointVolume* volume, UINT_PTR handle, IAudioEndpointVolumeCallback** volumeCallback);
 	HRESULT UnregisterControlChangeNotify(IAudioEndpointVolume* volume, IAudioEndpointVolumeCallback* volumeCallback);
 
 #ifdef __cplusplus
@@ -79,12 +79,12 @@ typedef struct { void *data; GoInt len; GoInt cap; } GoSlice;
 extern "C" {
 #endif
 
-extern __declspec(dllexport) HRESULT OnDeviceStateChangedCallback(LPCWSTR pwstrDeviceId, DWORD dwNewState);
-extern __declspec(dllexport) HRESULT OnDeviceAddedCallback(LPCWSTR pwstrDeviceId);
-extern __declspec(dllexport) HRESULT OnDeviceRemovedCallback(LPCWSTR pwstrDeviceId);
-extern __declspec(dllexport) HRESULT OnDefaultDeviceChangedCallback(EDataFlow flow, ERole role, LPCWSTR pwstrDefaultDeviceId);
-extern __declspec(dllexport) HRESULT OnPropertyValueChangedCallback(LPCWSTR pwstrDeviceId, PROPERTYKEY key);
-extern __declspec(dllexport) HRESULT OnEndpointVolumeChangeNotify(PAUDIO_VOLUME_NOTIFICATION_DATA pNotify);
+extern __declspec(dllexport) HRESULT OnDeviceStateChangedCallback(UINT_PTR handle, LPCWSTR pwstrDeviceId, DWORD dwNewState);
+extern __declspec(dllexport) HRESULT OnDeviceAddedCallback(UINT_PTR handle, LPCWSTR pwstrDeviceId);
+extern __declspec(dllexport) HRESULT OnDeviceRemovedCallback(UINT_PTR handle, LPCWSTR pwstrDeviceId);
+extern __declspec(dllexport) HRESULT OnDefaultDeviceChangedCallback(UINT_PTR handle, EDataFlow flow, ERole role, LPCWSTR pwstrDefaultDeviceId);
+extern __declspec(dllexport) HRESULT OnPropertyValueChangedCallback(UINT_PTR handle, LPCWSTR pwstrDeviceId, PROPERTYKEY key);
+extern __declspec(dllexport) HRESULT OnEndpointVolumeChangeNotify(UINT_PTR handle, PAUDIO_VOLUME_NOTIFICATION_DATA pNotify);
 
 #ifdef __cplusplus
 }
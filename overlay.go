@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// OverlayAnchor is the corner of the screen the overlay is kept in
+type OverlayAnchor string
+
+const (
+	// AnchorNone leaves the overlay where it was moved
+	AnchorNone        OverlayAnchor = ""
+	AnchorTopLeft     OverlayAnchor = "top-left"
+	AnchorTopRight    OverlayAnchor = "top-right"
+	AnchorBottomLeft  OverlayAnchor = "bottom-left"
+	AnchorBottomRight OverlayAnchor = "bottom-right"
+)
+
+type OverlayState struct {
+	WindowState
+
+	Anchor OverlayAnchor
+	// MarginX and MarginY are the distances from the anchor corner
+	MarginX, MarginY int
+	// Screen is the ID of the screen the overlay is anchored to, "" for the primary one
+	Screen string
+
+	// Scale and Opacity are applied by the overlay page itself
+	Scale   float64
+	Opacity float64
+
+	// ClickThrough lets the mouse events reach the windows under the overlay
+	ClickThrough bool
+	// AutoHide shows the overlay only while muted
+	AutoHide bool
+}
+
+const (
+	overlayMinScale   = 0.5
+	overlayMaxScale   = 3
+	overlayMinOpacity = 0.2
+)
+
+var ErrOverlayConfig = errors.New("invalid overlay config")
+
+// normalize gives a value to the settings missing from older save files
+func (o *OverlayState) normalize() {
+	if o.Scale == 0 {
+		o.Scale = 1
+	}
+	if o.Opacity == 0 {
+		o.Opacity = 1
+	}
+}
+
+func (o OverlayState) validate() error {
+	switch o.Anchor {
+	case AnchorNone, AnchorTopLeft, AnchorTopRight, AnchorBottomLeft, AnchorBottomRight:
+	default:
+		return fmt.Errorf("%w: unknown anchor %s", ErrOverlayConfig, o.Anchor)
+	}
+
+	if o.MarginX < 0 || o.MarginY < 0 {
+		return fmt.Errorf("%w: negative margin", ErrOverlayConfig)
+	}
+	if o.Scale < overlayMinScale || o.Scale > overlayMaxScale {
+		return fmt.Errorf("%w: scale %v out of [%v, %v]", ErrOverlayConfig, o.Scale, overlayMinScale, overlayMaxScale)
+	}
+	if o.Opacity < overlayMinOpacity || o.Opacity > 1 {
+		return fmt.Errorf("%w: opacity %v out of [%v, 1]", ErrOverlayConfig, o.Opacity, overlayMinOpacity)
+	}
+
+	return nil
+}
+
+func (w *WindowService) GetOverlayConfig() OverlayState {
+	return w.OverlayState
+}
+
+// SetOverlayConfig changes the overlay settings, keeping its current position and size
+func (w *WindowService) SetOverlayConfig(cfg OverlayState) error {
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	cfg.WindowState = w.OverlayState.WindowState
+	w.OverlayState = cfg
+	slog.Info("overlay config changed", "anchor", cfg.Anchor, "screen", cfg.Screen, "scale", cfg.Scale, "opacity", cfg.Opacity, "clickThrough", cfg.ClickThrough, "autoHide", cfg.AutoHide)
+
+	w.applyOverlayConfig()
+	bus.Emit("overlay-config-update", w.OverlayState)
+
+	return w.updateSaveData()
+}
+
+func (w *WindowService) applyOverlayConfig() {
+	if w.overlay == nil {
+		return
+	}
+
+	w.overlay.SetIgnoreMouseEvents(w.OverlayState.ClickThrough)
+	w.placeOverlay()
+
+	state, err := audioService.GetState()
+	if err == nil {
+		w.updateOverlayVisibility(state.Muted)
+	}
+}
+
+// placeOverlay moves the overlay to its anchor corner, if any
+func (w *WindowService) placeOverlay() {
+	cfg := w.OverlayState
+	if w.overlay == nil || cfg.Anchor == AnchorNone {
+		return
+	}
+
+	screen, err := w.overlayScreen()
+	if err != nil {
+		slog.Warn("overlay screen not found", "screen", cfg.Screen, "err", err)
+		return
+	}
+
+	area := screen.WorkArea
+	width, height := w.overlay.Size()
+
+	x, y := area.X+cfg.MarginX, area.Y+cfg.MarginY
+	if cfg.Anchor == AnchorTopRight || cfg.Anchor == AnchorBottomRight {
+		x = area.X + area.Width - width - cfg.MarginX
+	}
+	if cfg.Anchor == AnchorBottomLeft || cfg.Anchor == AnchorBottomRight {
+		y = area.Y + area.Height - height - cfg.MarginY
+	}
+
+	w.overlay.SetPosition(x, y)
+}
+
+// overlayScreen returns the configured screen, or the primary one
+// if it is not set or not connected
+func (w *WindowService) overlayScreen() (*application.Screen, error) {
+	screens, err := app.GetScreens()
+	if err != nil {
+		return nil, err
+	}
+
+	screen, _ := findScreen(screens, w.OverlayState.Screen)
+	if screen == nil {
+		return nil, errors.New("no screen connected")
+	}
+	return screen, nil
+}
+
+// updateOverlayVisibility shows the overlay only while muted, if AutoHide is set
+func (w *WindowService) updateOverlayVisibility(muted bool) {
+	if w.overlay == nil {
+		return
+	}
+
+	visible := !w.OverlayState.AutoHide || muted
+	if visible {
+		w.overlay.Show()
+	} else {
+		w.overlay.Hide()
+	}
+	w.setOverlayMetering(visible)
+}
+
+// setOverlayMetering subscribes to the level of the selected device only
+// while the overlay is visible, so that the capture streams are not kept
+// open when nobody is watching
+func (w *WindowService) setOverlayMetering(enabled bool) {
+	if !w.overlayMetering.CompareAndSwap(!enabled, enabled) {
+		return
+	}
+
+	var err error
+	if enabled {
+		err = audioService.SubscribeSelectedLevel()
+	} else {
+		err = audioService.UnsubscribeSelectedLevel()
+	}
+
+	if err != nil {
+		slog.Warn("overlay metering update failed", "enabled", enabled, "err", err)
+		if enabled {
+			w.overlayMetering.Store(false)
+		}
+	}
+}
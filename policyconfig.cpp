@@ -0,0 +1,38 @@
+#include "policyconfig.h"
+
+//
+// IPolicyConfig is not documented, but it is the interface used by the
+// Sound control panel to change the default endpoints. Only the method
+// needed is declared with a usable signature, the others keep the slots
+//
+
+static const CLSID CLSID_PolicyConfigClient = { 0x870af99c, 0x171d, 0x4f9e, { 0xaf, 0x0d, 0xe6, 0x3d, 0xf4, 0x0c, 0x2b, 0xc9 } };
+static const IID IID_IPolicyConfig = { 0xf8679f50, 0x850a, 0x41cf, { 0x9c, 0x72, 0x43, 0x0f, 0x29, 0x02, 0x90, 0xc8 } };
+
+class IPolicyConfig : public IUnknown {
+public:
+	virtual HRESULT STDMETHODCALLTYPE GetMixFormat(LPCWSTR, void**) = 0;
+	virtual HRESULT STDMETHODCALLTYPE GetDeviceFormat(LPCWSTR, INT, void**) = 0;
+	virtual HRESULT STDMETHODCALLTYPE ResetDeviceFormat(LPCWSTR) = 0;
+	virtual HRESULT STDMETHODCALLTYPE SetDeviceFormat(LPCWSTR, void*, void*) = 0;
+	virtual HRESULT STDMETHODCALLTYPE GetProcessingPeriod(LPCWSTR, INT, PINT64, PINT64) = 0;
+	virtual HRESULT STDMETHODCALLTYPE SetProcessingPeriod(LPCWSTR, PINT64) = 0;
+	virtual HRESULT STDMETHODCALLTYPE GetShareMode(LPCWSTR, void*) = 0;
+	virtual HRESULT STDMETHODCALLTYPE SetShareMode(LPCWSTR, void*) = 0;
+	virtual HRESULT STDMETHODCALLTYPE GetPropertyValue(LPCWSTR, const PROPERTYKEY&, PROPVARIANT*) = 0;
+	virtual HRESULT STDMETHODCALLTYPE SetPropertyValue(LPCWSTR, const PROPERTYKEY&, PROPVARIANT*) = 0;
+	virtual HRESULT STDMETHODCALLTYPE SetDefaultEndpoint(LPCWSTR id, ERole role) = 0;
+	virtual HRESULT STDMETHODCALLTYPE SetEndpointVisibility(LPCWSTR, INT) = 0;
+};
+
+HRESULT PolicyConfig_SetDefaultEndpoint(LPCWSTR id, ERole role) {
+	IPolicyConfig* policyConfig;
+	HRESULT hr = CoCreateInstance(CLSID_PolicyConfigClient, NULL, CLSCTX_ALL, IID_IPolicyConfig, (void**)&policyConfig);
+	if (FAILED(hr)) {
+		return hr;
+	}
+
+	hr = policyConfig->SetDefaultEndpoint(id, role);
+	policyConfig->Release();
+	return hr;
+}
@@ -0,0 +1,17 @@
+#ifndef POLICY_CONFIG_H
+#define POLICY_CONFIG_H
+
+#include <windows.h>
+#include <mmdeviceapi.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif // __cplusplus
+
+	HRESULT PolicyConfig_SetDefaultEndpoint(LPCWSTR id, ERole role);
+
+#ifdef __cplusplus
+}
+#endif // __cplusplus
+
+#endif // POLICY_CONFIG_H
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	recoveryAttempts = 3
+	recoveryBackoff  = 250 * time.Millisecond
+)
+
+// ReconnectState is present in the State while a device that has been
+// invalidated (driver reset, Bluetooth drop, ...) is being re-acquired
+type ReconnectState struct {
+	DeviceID    string
+	Attempt     int
+	MaxAttempts int
+	Failed      bool
+}
+
+// withRecovery runs op and, if it fails because the device has been
+// invalidated, starts re-acquiring the device in the background, up to
+// recoveryAttempts times with an increasing delay. The attempts go through
+// the event queue, so the lock is not held while waiting. op is not run
+// again: once the device is back its state is read again from the endpoint
+func (s *AudioService) withRecovery(device *Device, op func() error) error {
+	err := op()
+	if !errors.Is(err, ErrDeviceGone) {
+		if err == nil && s.Reconnect != nil && s.Reconnect.DeviceID == device.ID {
+			s.Reconnect = nil
+		}
+		return err
+	}
+
+	if s.Reconnect != nil && s.Reconnect.DeviceID == device.ID && !s.Reconnect.Failed {
+		// Already reconnecting
+		return err
+	}
+
+	slog.Warn("device invalidated, reconnecting", "device", device.ID, "err", err)
+
+	s.Reconnect = &ReconnectState{
+		DeviceID:    device.ID,
+		Attempt:     1,
+		MaxAttempts: recoveryAttempts,
+	}
+	s.scheduleReconnect(device.ID, 1)
+	s.updateFrontend(false, OriginExternal)
+
+	return err
+}
+
+// scheduleReconnect enqueues the given reconnect attempt after its backoff
+func (s *AudioService) scheduleReconnect(id string, attempt int) {
+	events := s.events
+	time.AfterFunc(recoveryBackoff*time.Duration(attempt), func() {
+		events.push(backendEvent{kind: reconnectEvent, deviceID: id, attempt: attempt})
+	})
+}
+
+// handleReconnect re-acquires the device of a reconnect attempt,
+// scheduling the next one if it is still unavailable
+func (s *AudioService) handleReconnect(ev backendEvent) error {
+	// Superseded by a success or by another device
+	if s.Reconnect == nil || s.Reconnect.DeviceID != ev.deviceID || s.Reconnect.Attempt != ev.attempt {
+		return nil
+	}
+
+	device, ok := s.Devices[ev.deviceID]
+	if !ok {
+		// Removed in the meantime, the device events take care of it
+		s.Reconnect = nil
+		return s.updateFrontend(false, OriginExternal)
+	}
+
+	err := device.reacquire(s.deviceEnum, device.ID == s.Selected && device.isActive())
+
+	if err == nil {
+		slog.Info("device reconnected", "device", device.ID, "attempt", ev.attempt)
+		s.Reconnect = nil
+		return s.updateFrontend(false, OriginExternal)
+	}
+
+	slog.Warn("device reconnect attempt failed", "device", device.ID, "attempt", ev.attempt, "err", err)
+
+	retry := errors.Is(err, ErrDeviceGone) || errors.Is(err, ErrDeviceNotFound)
+	if retry && ev.attempt < recoveryAttempts {
+		s.Reconnect.Attempt++
+		s.scheduleReconnect(device.ID, s.Reconnect.Attempt)
+	} else {
+		s.Reconnect.Failed = true
+	}
+
+	return s.updateFrontend(false, OriginExternal)
+}
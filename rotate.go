@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// rotatingFile is an append-only file that, once it would grow over maxSize,
+// is renamed to <name>.1<ext> (shifting the older ones) and reopened empty,
+// keeping at most maxFiles files, the current one included
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	// onRotate, if set, is called with the newly opened file after every rotation
+	onRotate func(f *os.File) error
+
+	f    *os.File
+	size int64
+	m    sync.Mutex
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: max(maxFiles, 1),
+	}
+
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		return fmt.Errorf("file open: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("file stat: %w", err)
+	}
+
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p to the current file, rotating it first if needed:
+// p is never split between two files
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return fmt.Errorf("file close: %w", err)
+	}
+
+	for i := r.maxFiles - 1; i > 0; i-- {
+		err = os.Rename(r.rotatedPath(i-1), r.rotatedPath(i))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("file rotate: %w", err)
+		}
+	}
+
+	err = r.open()
+	if err != nil {
+		return err
+	}
+
+	if r.onRotate != nil {
+		return r.onRotate(r.f)
+	}
+	return nil
+}
+
+// File returns the file currently written
+func (r *rotatingFile) File() *os.File {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.f
+}
+
+func (r *rotatingFile) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// rotatedPath returns the path of the n-th most recent file,
+// where 0 is the one currently written
+func (r *rotatingFile) rotatedPath(n int) string {
+	if n == 0 {
+		return r.path
+	}
+
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(r.path, ext)
+	return fmt.Sprintf("%s.%d%s", base, n, ext)
+}
+
+// paths returns every path that may hold data, from the oldest to the current one
+func (r *rotatingFile) paths() []string {
+	paths := make([]string, 0, r.maxFiles)
+	for i := r.maxFiles - 1; i >= 0; i-- {
+		paths = append(paths, r.rotatedPath(i))
+	}
+	return paths
+}
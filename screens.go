@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// screenCheckInterval is how often the screen layout is compared with the
+// previous one, as there is no display change event to listen to
+const screenCheckInterval = 2 * time.Second
+
+// findScreen returns the screen with the given ID, or the primary one and false
+// if it is not connected anymore
+func findScreen(screens []*application.Screen, id string) (*application.Screen, bool) {
+	var primary *application.Screen
+	for _, screen := range screens {
+		if id != "" && screen.ID == id {
+			return screen, true
+		}
+		if screen.IsPrimary {
+			primary = screen
+		}
+	}
+
+	if primary == nil && len(screens) > 0 {
+		primary = screens[0]
+	}
+	return primary, false
+}
+
+// screenLayout describes the connected screens, to detect when they change
+func screenLayout(screens []*application.Screen) string {
+	var layout []string
+	for _, screen := range screens {
+		area := screen.WorkArea
+		layout = append(layout, fmt.Sprintf("%s:%d,%d,%dx%d", screen.ID, area.X, area.Y, area.Width, area.Height))
+	}
+
+	slices.Sort(layout)
+	return strings.Join(layout, ";")
+}
+
+// absolutePosition converts the saved position to screen coordinates, keeping the
+// window inside the work area of its monitor. It returns false if the position
+// is unset or its monitor is gone, in which case the window should be centered
+func (state *WindowState) absolutePosition(screens []*application.Screen) (x, y int, ok bool) {
+	if !state.Placed {
+		return 0, 0, false
+	}
+
+	screen, found := findScreen(screens, state.Monitor)
+	if !found || screen == nil {
+		return 0, 0, false
+	}
+
+	area := screen.WorkArea
+	x = max(0, min(state.X, area.Width-state.Width))
+	y = max(0, min(state.Y, area.Height-state.Height))
+
+	return area.X + x, area.Y + y, true
+}
+
+// watchScreens revalidates the window positions every time the screen layout changes
+func (w *WindowService) watchScreens() {
+	ticker := time.NewTicker(screenCheckInterval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.screensStop:
+			return
+		}
+
+		screens, err := app.GetScreens()
+		if err != nil {
+			slog.Debug("screen layout read failed", "err", err)
+			continue
+		}
+
+		layout := screenLayout(screens)
+		if layout == last {
+			continue
+		}
+
+		if last != "" {
+			slog.Info("screen layout changed", "screens", len(screens))
+			w.validatePositions(screens)
+		}
+		last = layout
+	}
+}
+
+// validatePositions moves the windows back on a connected screen
+func (w *WindowService) validatePositions(screens []*application.Screen) {
+	if w.window != nil {
+		validateWindowPosition(w.window, &w.WindowState, screens)
+	}
+
+	if w.overlay != nil {
+		if w.OverlayState.Anchor != AnchorNone {
+			w.placeOverlay()
+		} else {
+			validateWindowPosition(w.overlay, &w.OverlayState.WindowState, screens)
+		}
+	}
+}
+
+func validateWindowPosition(window *application.WebviewWindow, state *WindowState, screens []*application.Screen) {
+	x, y, ok := state.absolutePosition(screens)
+	if !ok {
+		slog.Info("window monitor gone, recentering", "monitor", state.Monitor)
+		window.Center()
+		return
+	}
+
+	curX, curY := window.Position()
+	if curX != x || curY != y {
+		window.SetPosition(x, y)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+/*
+#include "winhelper.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SpeechPolicy tells what happens, besides the "speaking-while-muted"
+// event, when speech is detected on the muted device
+type SpeechPolicy string
+
+const (
+	SpeechNotify     SpeechPolicy = "notify"
+	SpeechSound      SpeechPolicy = "sound"
+	SpeechAutoUnmute SpeechPolicy = "auto-unmute"
+)
+
+type SpeechDetectConfig struct {
+	Enabled bool
+	// Threshold is the peak level, from 0 to 1, considered speech
+	Threshold float32
+	// HoldMillis is how long the level must stay over the threshold
+	HoldMillis int
+	Policy     SpeechPolicy
+}
+
+// defaultSpeechDetectConfig leaves the detection off: while enabled the
+// muted mic is kept open for metering, so it is up to the user to opt in
+var defaultSpeechDetectConfig = SpeechDetectConfig{
+	Enabled:    false,
+	Threshold:  0.1,
+	HoldMillis: 600,
+	Policy:     SpeechNotify,
+}
+
+// speechGap is the longest drop under the threshold that does not
+// interrupt speech, like the pauses between words
+const speechGap = 300 * time.Millisecond
+
+var ErrSpeechDetectConfig = errors.New("invalid speech detection config")
+
+type SpeakingWhileMutedEvent struct {
+	DeviceID string
+	Level    float32
+	Policy   SpeechPolicy
+}
+
+// speechDetector finds sustained input over a threshold in a stream
+// of level samples. It does not depend on the audio backend, so it
+// can be fed with synthetic samples
+type speechDetector struct {
+	threshold float32
+	hold      time.Duration
+
+	start     time.Time // zero when no speech is going on
+	lastAbove time.Time
+	fired     bool
+}
+
+func newSpeechDetector(cfg SpeechDetectConfig) *speechDetector {
+	return &speechDetector{
+		threshold: cfg.Threshold,
+		hold:      time.Duration(cfg.HoldMillis) * time.Millisecond,
+	}
+}
+
+// sample feeds the level measured at the given time and reports whether
+// speech has just been detected: this happens once for every stretch
+// of speech, which ends after speechGap without input over the threshold
+func (d *speechDetector) sample(level float32, at time.Time) bool {
+	if level < d.threshold {
+		if !d.start.IsZero() && at.Sub(d.lastAbove) > speechGap {
+			d.reset()
+		}
+		return false
+	}
+
+	if d.start.IsZero() {
+		d.start = at
+	}
+	d.lastAbove = at
+
+	if d.fired || at.Sub(d.start) < d.hold {
+		return false
+	}
+
+	d.fired = true
+	return true
+}
+
+func (d *speechDetector) reset() {
+	d.start, d.lastAbove, d.fired = time.Time{}, time.Time{}, false
+}
+
+func (cfg SpeechDetectConfig) validate() error {
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		return fmt.Errorf("%w: threshold %v out of (0, 1]", ErrSpeechDetectConfig, cfg.Threshold)
+	}
+	if cfg.HoldMillis < 0 {
+		return fmt.Errorf("%w: negative hold time", ErrSpeechDetectConfig)
+	}
+
+	switch cfg.Policy {
+	case SpeechNotify, SpeechSound, SpeechAutoUnmute:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown policy %s", ErrSpeechDetectConfig, cfg.Policy)
+	}
+}
+
+func (s *AudioService) SetSpeechDetectConfig(cfg SpeechDetectConfig) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	s.SpeechDetect = cfg
+	s.speech = newSpeechDetector(cfg)
+	slog.Info("speech detection config changed", "enabled", cfg.Enabled, "threshold", cfg.Threshold, "hold", cfg.HoldMillis, "policy", cfg.Policy)
+
+	return s.updateFrontend(false, OriginSelf)
+}
+
+// detectSpeech is called with every level sample of the selected device
+func (s *AudioService) detectSpeech(sample LevelSample) error {
+	if !s.SpeechDetect.Enabled || !s.Muted {
+		s.speech.reset()
+		return nil
+	}
+
+	level := sample.Level
+	if !s.speech.sample(level, sample.Time) {
+		return nil
+	}
+
+	slog.Info("speaking while muted", "device", s.Selected, "level", level, "policy", s.SpeechDetect.Policy)
+	s.record(HistoryEvent{Kind: HistorySpeakingWhileMuted, DeviceID: s.Selected, Detail: string(s.SpeechDetect.Policy)})
+	s.publisher.Emit("speaking-while-muted", SpeakingWhileMutedEvent{
+		DeviceID: s.Selected,
+		Level:    level,
+		Policy:   s.SpeechDetect.Policy,
+	})
+
+	switch s.SpeechDetect.Policy {
+	case SpeechSound:
+		C.playNotificationSound()
+	case SpeechAutoUnmute:
+		if s.MuteLock {
+			slog.Info("auto-unmute skipped, mute lock engaged", "device", s.Selected)
+			return nil
+		}
+
+		err := s.setPrefMute(false)
+		if err != nil {
+			return err
+		}
+
+		muted := false
+		s.record(HistoryEvent{Kind: HistoryMuteChanged, DeviceID: s.Selected, Origin: OriginSelf, Muted: &muted, Detail: "auto-unmute"})
+		return s.updateFrontend(false, OriginSelf)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// levelStep is a synthetic level sample, at a time relative to the start of the trace
+type levelStep struct {
+	ms    int
+	level float32
+}
+
+// flatTrace returns samples of the given level every meterInterval, from..to ms included
+func flatTrace(from, to int, level float32) []levelStep {
+	var steps []levelStep
+	for ms := from; ms <= to; ms += int(meterInterval / time.Millisecond) {
+		steps = append(steps, levelStep{ms, level})
+	}
+	return steps
+}
+
+func concatTraces(traces ...[]levelStep) []levelStep {
+	var steps []levelStep
+	for _, trace := range traces {
+		steps = append(steps, trace...)
+	}
+	return steps
+}
+
+func TestSpeechDetector(t *testing.T) {
+	cfg := SpeechDetectConfig{Enabled: true, Threshold: 0.1, HoldMillis: 600, Policy: SpeechNotify}
+
+	tests := []struct {
+		name  string
+		trace []levelStep
+		// fires are the times, in ms, at which speech must be detected
+		fires []int
+	}{
+		{
+			name:  "silence",
+			trace: flatTrace(0, 2000, 0.01),
+		},
+		{
+			name:  "below threshold",
+			trace: flatTrace(0, 2000, 0.099),
+		},
+		{
+			name:  "at threshold counts as speech",
+			trace: flatTrace(0, 1000, 0.1),
+			fires: []int{600},
+		},
+		{
+			name:  "shorter than hold",
+			trace: concatTraces(flatTrace(0, 550, 0.5), flatTrace(600, 2000, 0)),
+		},
+		{
+			name:  "sustained speech fires once",
+			trace: flatTrace(0, 3000, 0.5),
+			fires: []int{600},
+		},
+		{
+			name: "pauses shorter than the gap do not interrupt speech",
+			trace: concatTraces(
+				flatTrace(0, 300, 0.5),
+				flatTrace(350, 550, 0),
+				flatTrace(600, 1000, 0.5),
+			),
+			fires: []int{600},
+		},
+		{
+			name: "pauses longer than the gap restart the hold",
+			trace: concatTraces(
+				flatTrace(0, 300, 0.5),
+				flatTrace(350, 750, 0),
+				flatTrace(800, 1300, 0.5),
+				flatTrace(1350, 1500, 0.5),
+			),
+			fires: []int{1400},
+		},
+		{
+			name: "a new stretch of speech fires again",
+			trace: concatTraces(
+				flatTrace(0, 700, 0.5),
+				flatTrace(750, 1500, 0),
+				flatTrace(1550, 2200, 0.5),
+			),
+			fires: []int{600, 2150},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newSpeechDetector(cfg)
+			start := time.Now()
+
+			var fires []int
+			for _, step := range tt.trace {
+				if d.sample(step.level, start.Add(time.Duration(step.ms)*time.Millisecond)) {
+					fires = append(fires, step.ms)
+				}
+			}
+
+			if !slices.Equal(fires, tt.fires) {
+				t.Errorf("fired at %v ms, want %v ms", fires, tt.fires)
+			}
+		})
+	}
+}
+
+func TestSpeechDetectorZeroHold(t *testing.T) {
+	d := newSpeechDetector(SpeechDetectConfig{Threshold: 0.2})
+	start := time.Now()
+
+	if d.sample(0.1, start) {
+		t.Error("fired below the threshold")
+	}
+	if !d.sample(0.3, start.Add(meterInterval)) {
+		t.Error("did not fire on the first sample over the threshold")
+	}
+	if d.sample(0.3, start.Add(2*meterInterval)) {
+		t.Error("fired twice for the same speech")
+	}
+
+	d.reset()
+	if !d.sample(0.3, start.Add(3*meterInterval)) {
+		t.Error("did not fire again after reset")
+	}
+}
+
+func TestSpeechDetectConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SpeechDetectConfig
+		ok   bool
+	}{
+		{"default", defaultSpeechDetectConfig, true},
+		{"max threshold", SpeechDetectConfig{Threshold: 1, Policy: SpeechSound}, true},
+		{"zero threshold", SpeechDetectConfig{Threshold: 0, Policy: SpeechNotify}, false},
+		{"threshold over 1", SpeechDetectConfig{Threshold: 1.1, Policy: SpeechNotify}, false},
+		{"negative hold", SpeechDetectConfig{Threshold: 0.1, HoldMillis: -1, Policy: SpeechNotify}, false},
+		{"unknown policy", SpeechDetectConfig{Threshold: 0.1, Policy: "shout"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrSpeechDetectConfig) {
+				t.Errorf("got %v, want %v", err, ErrSpeechDetectConfig)
+			}
+		})
+	}
+}
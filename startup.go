@@ -0,0 +1,37 @@
+package main
+
+import "log/slog"
+
+type StartupConfig struct {
+	// Autostart launches the app at login
+	Autostart bool
+	// StartMinimised creates only the overlay at launch, not the dashboard
+	StartMinimised bool
+}
+
+// GetStartupConfig returns the startup settings, with Autostart
+// reflecting the system entry in case it was changed outside of the app
+func (w *WindowService) GetStartupConfig() StartupConfig {
+	enabled, err := autostartEnabled()
+	if err != nil {
+		slog.Warn("autostart read failed", "err", err)
+	} else {
+		w.StartupConfig.Autostart = enabled
+	}
+
+	return w.StartupConfig
+}
+
+func (w *WindowService) SetStartupConfig(cfg StartupConfig) error {
+	if cfg.Autostart != w.StartupConfig.Autostart {
+		err := setAutostart(cfg.Autostart)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.StartupConfig = cfg
+	slog.Info("startup config changed", "autostart", cfg.Autostart, "startMinimised", cfg.StartMinimised)
+
+	return w.updateSaveData()
+}
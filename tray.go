@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+//go:embed build/appicon.png
+var appIcon []byte
+
+// Tray is the system tray icon: its image shows whether the selected device
+// is muted and its menu mirrors the device list of the dashboard
+type Tray struct {
+	systray   *application.SystemTray
+	icon      []byte
+	mutedIcon []byte
+
+	muted bool
+	m     sync.Mutex
+}
+
+func newTray() (*Tray, error) {
+	mutedIcon, err := markIcon(appIcon, color.RGBA{R: 230, G: 60, B: 60, A: 255})
+	if err != nil {
+		return nil, fmt.Errorf("tray icon: %w", err)
+	}
+
+	t := &Tray{
+		systray:   app.NewSystemTray(),
+		icon:      appIcon,
+		mutedIcon: mutedIcon,
+	}
+	t.systray.SetIcon(appIcon).SetDarkModeIcon(appIcon)
+	t.systray.OnClick(windowService.CreateWindow)
+
+	state, err := audioService.GetState()
+	if err != nil {
+		return nil, err
+	}
+	t.update(state)
+
+	bus.On("audio-device-update", func(data ...any) {
+		// GetState returns a copy that is safe to read without the service lock
+		state, err := audioService.GetState()
+		if err != nil {
+			slog.Warn("tray update failed", "err", err)
+			return
+		}
+		t.update(state)
+	})
+
+	return t, nil
+}
+
+func (t *Tray) update(state State) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if state.Muted != t.muted {
+		icon := t.icon
+		if state.Muted {
+			icon = t.mutedIcon
+		}
+		t.systray.SetIcon(icon).SetDarkModeIcon(icon)
+		t.muted = state.Muted
+	}
+
+	t.systray.SetMenu(t.buildMenu(state))
+}
+
+func (t *Tray) buildMenu(state State) *application.Menu {
+	menu := application.NewMenu()
+
+	menu.AddCheckbox("Muted", state.Muted).OnClick(func(*application.Context) {
+		err := audioService.ToggleSelected()
+		if err != nil {
+			slog.Error("tray toggle failed", "op", "ToggleSelected", "err", err)
+		}
+	})
+	menu.AddSeparator()
+
+	for _, device := range trayDevices(state) {
+		_, pref := state.Prefs[device.ID]
+
+		label := device.Name
+		if pref {
+			label = "★ " + label
+		}
+
+		item := menu.AddRadio(label, device.ID == state.Selected)
+		item.SetEnabled(device.State == DeviceActive)
+
+		id := device.ID
+		item.OnClick(func(*application.Context) {
+			err := audioService.SetDevice(id)
+			if err != nil {
+				slog.Error("tray device selection failed", "op", "SetDevice", "device", id, "err", err)
+			}
+		})
+	}
+	menu.AddSeparator()
+
+	menu.Add("Open dashboard").OnClick(func(*application.Context) {
+		windowService.CreateWindow()
+	})
+	menu.Add("Exit").OnClick(func(*application.Context) {
+		quit()
+	})
+
+	return menu
+}
+
+// trayDevices returns the devices shown in the menu like in the
+// dashboard, with the starred ones first
+func trayDevices(state State) []DeviceState {
+	var devices []DeviceState
+	for id, device := range state.Prefs {
+		current := device.DeviceState
+		if d, ok := state.Devices[id]; ok {
+			current = d.DeviceState
+		} else {
+			current.State = DeviceNotPresent
+		}
+		devices = append(devices, current)
+	}
+	for id, device := range state.Devices {
+		if _, ok := state.Prefs[id]; !ok && device.isActive() {
+			devices = append(devices, device.DeviceState)
+		}
+	}
+
+	slices.SortStableFunc(devices, func(a, b DeviceState) int {
+		_, aPref := state.Prefs[a.ID]
+		_, bPref := state.Prefs[b.ID]
+		switch {
+		case aPref && !bPref:
+			return -1
+		case !aPref && bPref:
+			return 1
+		default:
+			return strings.Compare(a.Name, b.Name)
+		}
+	})
+
+	return devices
+}
+
+// markIcon returns a copy of the PNG icon with a dot of the given
+// color in its bottom right corner
+func markIcon(icon []byte, c color.Color) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(icon))
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, src, b.Min, draw.Src)
+
+	// Big enough to be seen at the tray size
+	r := b.Dx() / 4
+	cx, cy := b.Max.X-r-1, b.Max.Y-r-1
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+				dst.Set(x, y, c)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, dst)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LevelSample is the peak level, from 0 to 1, measured at Time
+type LevelSample struct {
+	Level float32
+	Time  time.Time
+}
+
+// LevelSource provides the level samples fed to voxMachine and
+// speechDetector. Devices implement it with their peak meter, while
+// recorded sample traces can be replayed through it
+type LevelSource interface {
+	Sample() (LevelSample, error)
+}
+
+func (d *Device) Sample() (LevelSample, error) {
+	level, err := d.getPeak()
+	return LevelSample{Level: level, Time: time.Now()}, err
+}
+
+type VOXConfig struct {
+	Enabled bool
+	// Threshold is the peak level, from 0 to 1, that opens the microphone
+	Threshold float32
+	// AttackMillis is how long the level must stay over the threshold
+	// before unmuting
+	AttackMillis int
+	// HangMillis is how long the microphone stays open after the
+	// level drops under the threshold
+	HangMillis int
+}
+
+var defaultVOXConfig = VOXConfig{
+	Threshold:    0.08,
+	AttackMillis: 100,
+	HangMillis:   800,
+}
+
+var ErrVOXConfig = errors.New("invalid VOX config")
+
+type voxState int
+
+const (
+	// voxClosed: muted, waiting for input over the threshold
+	voxClosed voxState = iota
+	// voxAttack: muted, input over the threshold for less than the attack time
+	voxAttack
+	// voxOpen: unmuted, input over the threshold
+	voxOpen
+	// voxHang: unmuted, input under the threshold for less than the hang time
+	voxHang
+)
+
+type voxAction int
+
+const (
+	voxNone voxAction = iota
+	voxUnmute
+	voxMute
+)
+
+// voxMachine is the state machine of the VOX mode: it is fed with level
+// samples and returns when the microphone must be unmuted or muted.
+// It does not depend on the audio backend
+type voxMachine struct {
+	threshold float32
+	attack    time.Duration
+	hang      time.Duration
+
+	state voxState
+	since time.Time // when the current state was entered
+}
+
+func newVOXMachine(cfg VOXConfig) *voxMachine {
+	return &voxMachine{
+		threshold: cfg.Threshold,
+		attack:    time.Duration(cfg.AttackMillis) * time.Millisecond,
+		hang:      time.Duration(cfg.HangMillis) * time.Millisecond,
+	}
+}
+
+func (v *voxMachine) feed(sample LevelSample) voxAction {
+	above := sample.Level >= v.threshold
+
+	switch v.state {
+	case voxClosed:
+		if above {
+			v.enter(voxAttack, sample.Time)
+			// A zero attack time opens immediately
+			return v.feed(sample)
+		}
+	case voxAttack:
+		switch {
+		case !above:
+			v.enter(voxClosed, sample.Time)
+		case sample.Time.Sub(v.since) >= v.attack:
+			v.enter(voxOpen, sample.Time)
+			return voxUnmute
+		}
+	case voxOpen:
+		if !above {
+			v.enter(voxHang, sample.Time)
+		}
+	case voxHang:
+		switch {
+		case above:
+			v.enter(voxOpen, sample.Time)
+		case sample.Time.Sub(v.since) >= v.hang:
+			v.enter(voxClosed, sample.Time)
+			return voxMute
+		}
+	}
+
+	return voxNone
+}
+
+func (v *voxMachine) enter(state voxState, at time.Time) {
+	v.state, v.since = state, at
+}
+
+// reset brings the machine back to the closed state
+func (v *voxMachine) reset() {
+	v.state, v.since = voxClosed, time.Time{}
+}
+
+func (cfg VOXConfig) validate() error {
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		return fmt.Errorf("%w: threshold %v out of (0, 1]", ErrVOXConfig, cfg.Threshold)
+	}
+	if cfg.AttackMillis < 0 || cfg.HangMillis < 0 {
+		return fmt.Errorf("%w: negative attack or hang time", ErrVOXConfig)
+	}
+	return nil
+}
+
+// SetVOXConfig configures the VOX mode: when it is enabled the selected
+// device is muted and then unmuted only while there is voice activity
+func (s *AudioService) SetVOXConfig(cfg VOXConfig) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.running {
+		return ErrAudioServiceNotRunning
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		return err
+	}
+
+	enabling := cfg.Enabled && !s.VOX.Enabled
+	s.VOX = cfg
+	s.vox = newVOXMachine(cfg)
+	slog.Info("VOX config changed", "enabled", cfg.Enabled, "threshold", cfg.Threshold, "attack", cfg.AttackMillis, "hang", cfg.HangMillis)
+
+	return s.com.do(func() error {
+		if enabling && !s.Muted {
+			err := s.setVOXMute(true)
+			if err != nil {
+				return err
+			}
+		}
+		return s.updateFrontend(false, OriginSelf)
+	})
+}
+
+// feedVOX is called with every level sample of the selected device
+func (s *AudioService) feedVOX(sample LevelSample) error {
+	if s.MuteLock {
+		// The user wants the current state kept
+		s.vox.reset()
+		return nil
+	}
+
+	switch s.vox.feed(sample) {
+	case voxUnmute:
+		if !s.Muted {
+			return nil
+		}
+		err := s.setVOXMute(false)
+		if err != nil {
+			return err
+		}
+	case voxMute:
+		if s.Muted {
+			return nil
+		}
+		err := s.setVOXMute(true)
+		if err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	return s.updateFrontend(false, OriginSelf)
+}
+
+func (s *AudioService) setVOXMute(muted bool) error {
+	err := s.setPrefMute(muted)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("VOX mute changed", "device", s.Selected, "muted", muted)
+	s.record(HistoryEvent{Kind: HistoryMuteChanged, DeviceID: s.Selected, Origin: OriginSelf, Muted: &muted, Detail: "vox"})
+	return nil
+}
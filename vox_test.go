@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// traceSource replays a recorded level trace as a LevelSource
+type traceSource struct {
+	start time.Time
+	steps []levelStep
+}
+
+func (t *traceSource) Sample() (LevelSample, error) {
+	if len(t.steps) == 0 {
+		return LevelSample{}, io.EOF
+	}
+
+	step := t.steps[0]
+	t.steps = t.steps[1:]
+	return LevelSample{Level: step.level, Time: t.start.Add(time.Duration(step.ms) * time.Millisecond)}, nil
+}
+
+// voxDecision is a mute or unmute returned by voxMachine, at a time relative to the trace
+type voxDecision struct {
+	ms     int
+	action voxAction
+}
+
+func TestVOXMachine(t *testing.T) {
+	cfg := VOXConfig{Enabled: true, Threshold: 0.1, AttackMillis: 100, HangMillis: 800}
+
+	tests := []struct {
+		name      string
+		cfg       VOXConfig
+		trace     []levelStep
+		decisions []voxDecision
+		final     voxState
+	}{
+		{
+			name:  "silence stays closed",
+			cfg:   cfg,
+			trace: flatTrace(0, 2000, 0.02),
+			final: voxClosed,
+		},
+		{
+			name:  "a click shorter than the attack does not open",
+			cfg:   cfg,
+			trace: concatTraces(flatTrace(0, 50, 0.8), flatTrace(100, 1000, 0)),
+			final: voxClosed,
+		},
+		{
+			name:      "speech opens after the attack time",
+			cfg:       cfg,
+			trace:     flatTrace(0, 500, 0.4),
+			decisions: []voxDecision{{100, voxUnmute}},
+			final:     voxOpen,
+		},
+		{
+			name:      "zero attack opens on the first sample",
+			cfg:       VOXConfig{Threshold: 0.1, HangMillis: 800},
+			trace:     concatTraces(flatTrace(0, 100, 0), flatTrace(150, 300, 0.4)),
+			decisions: []voxDecision{{150, voxUnmute}},
+			final:     voxOpen,
+		},
+		{
+			name:      "silence mutes after the hang time",
+			cfg:       cfg,
+			trace:     concatTraces(flatTrace(0, 500, 0.4), flatTrace(550, 2000, 0)),
+			decisions: []voxDecision{{100, voxUnmute}, {1350, voxMute}},
+			final:     voxClosed,
+		},
+		{
+			name: "pauses shorter than the hang time keep it open",
+			cfg:  cfg,
+			trace: concatTraces(
+				flatTrace(0, 500, 0.4),
+				flatTrace(550, 1200, 0),
+				flatTrace(1250, 1500, 0.4),
+				flatTrace(1550, 2000, 0),
+			),
+			decisions: []voxDecision{{100, voxUnmute}},
+			final:     voxHang,
+		},
+		{
+			name: "recorded conversation",
+			cfg:  cfg,
+			trace: []levelStep{
+				{0, 0.01}, {50, 0.03}, {100, 0.15}, {150, 0.32}, {200, 0.41},
+				{250, 0.22}, {300, 0.05}, {350, 0.18}, {400, 0.27}, {450, 0.02},
+				{500, 0.01}, {900, 0.01}, {1300, 0.02}, {1350, 0.01}, {1400, 0.12},
+				{1450, 0.03}, {1500, 0.01}, {2000, 0.02}, {2300, 0.01},
+			},
+			decisions: []voxDecision{{200, voxUnmute}, {1300, voxMute}},
+			final:     voxClosed,
+		},
+		{
+			name:      "exactly at threshold counts as voice",
+			cfg:       cfg,
+			trace:     flatTrace(0, 200, 0.1),
+			decisions: []voxDecision{{100, voxUnmute}},
+			final:     voxOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newVOXMachine(tt.cfg)
+			src := &traceSource{start: time.Now(), steps: tt.trace}
+
+			var decisions []voxDecision
+			for {
+				sample, err := LevelSource(src).Sample()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if action := v.feed(sample); action != voxNone {
+					decisions = append(decisions, voxDecision{int(sample.Time.Sub(src.start) / time.Millisecond), action})
+				}
+			}
+
+			if !slices.Equal(decisions, tt.decisions) {
+				t.Errorf("decisions %v, want %v", decisions, tt.decisions)
+			}
+			if v.state != tt.final {
+				t.Errorf("final state %v, want %v", v.state, tt.final)
+			}
+		})
+	}
+}
+
+func TestVOXMachineReset(t *testing.T) {
+	v := newVOXMachine(VOXConfig{Threshold: 0.1, AttackMillis: 100, HangMillis: 800})
+	start := time.Now()
+
+	v.feed(LevelSample{Level: 0.5, Time: start})
+	v.reset()
+
+	// The attack starts again after a reset
+	if action := v.feed(LevelSample{Level: 0.5, Time: start.Add(100 * time.Millisecond)}); action != voxNone {
+		t.Errorf("got %v right after reset, want none", action)
+	}
+	if action := v.feed(LevelSample{Level: 0.5, Time: start.Add(200 * time.Millisecond)}); action != voxUnmute {
+		t.Errorf("got %v after the attack time, want unmute", action)
+	}
+}
+
+func TestVOXConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  VOXConfig
+		ok   bool
+	}{
+		{"default", defaultVOXConfig, true},
+		{"zero times", VOXConfig{Threshold: 0.5}, true},
+		{"zero threshold", VOXConfig{}, false},
+		{"threshold over 1", VOXConfig{Threshold: 2}, false},
+		{"negative attack", VOXConfig{Threshold: 0.1, AttackMillis: -1}, false},
+		{"negative hang", VOXConfig{Threshold: 0.1, HangMillis: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrVOXConfig) {
+				t.Errorf("got %v, want %v", err, ErrVOXConfig)
+			}
+		})
+	}
+}
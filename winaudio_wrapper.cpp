@@ -8,8 +8,16 @@ HRESULT CreateInstance(IMMDeviceEnumerator** deviceEnum) {
 // IMMDeviceEnumerator
 //
 
-HRESULT IMMDeviceEnumerator_EnumAudioEndpoints(IMMDeviceEnumerator* deviceEnum, IMMDeviceCollection** collection) {
-	return deviceEnum->EnumAudioEndpoints(eCapture, DEVICE_STATE_ACTIVE, collection);
+HRESULT IMMDeviceEnumerator_EnumAudioEndpoints(IMMDeviceEnumerator* deviceEnum, EDataFlow flow, DWORD stateMask, IMMDeviceCollection** collection) {
+	return deviceEnum->EnumAudioEndpoints(flow, stateMask, collection);
+}
+
+HRESULT IMMDeviceEnumerator_GetDevice(IMMDeviceEnumerator* deviceEnum, LPCWSTR id, IMMDevice** device) {
+	return deviceEnum->GetDevice(id, device);
+}
+
+HRESULT IMMDeviceEnumerator_GetDefaultAudioEndpoint(IMMDeviceEnumerator* deviceEnum, EDataFlow flow, ERole role, IMMDevice** device) {
+	return deviceEnum->GetDefaultAudioEndpoint(flow, role, device);
 }
 
 void IMMDeviceEnumerator_Release(IMMDeviceEnumerator* deviceEnum) {
@@ -54,6 +62,22 @@ HRESULT IMMDevice_Activate(IMMDevice* device, const IID* iid, DWORD dwClsCtx, PR
 	return device->Activate(*iid, dwClsCtx, pActivationParams, ppInterface);
 }
 
+HRESULT IMMDevice_GetDataFlow(IMMDevice* device, EDataFlow* flow) {
+	IMMEndpoint* endpoint;
+	HRESULT hr = device->QueryInterface(__uuidof(IMMEndpoint), (void**)&endpoint);
+	if (FAILED(hr)) {
+		return hr;
+	}
+
+	hr = endpoint->GetDataFlow(flow);
+	endpoint->Release();
+	return hr;
+}
+
+HRESULT IMMDevice_GetState(IMMDevice* device, DWORD* state) {
+	return device->GetState(state);
+}
+
 void IMMDevice_Release(IMMDevice* device) {
 	device->Release();
 }
@@ -75,10 +99,30 @@ void IPropertyStore_Release(IPropertyStore* store) {
 // PROPVARIANT
 //
 
+// Returns NULL if the property is not a string (for example when it is not set)
 LPWSTR PROPVARIANT_GetStringValue(PROPVARIANT* prop) {
+	if (prop->vt != VT_LPWSTR) {
+		return NULL;
+	}
 	return prop->pwszVal;
 }
 
+BOOL PROPVARIANT_GetUIntValue(PROPVARIANT* prop, UINT* value) {
+	if (prop->vt != VT_UI4) {
+		return FALSE;
+	}
+	*value = prop->ulVal;
+	return TRUE;
+}
+
+BOOL PROPVARIANT_GetGUIDValue(PROPVARIANT* prop, GUID* value) {
+	if (prop->vt != VT_CLSID) {
+		return FALSE;
+	}
+	*value = *prop->puuid;
+	return TRUE;
+}
+
 //
 // IAudioEndpointVolume
 //
@@ -91,6 +135,69 @@ HRESULT IAudioEndpointVolume_SetMute(IAudioEndpointVolume* volume, BOOL muted, L
 	return volume->SetMute(muted, context);
 }
 
+HRESULT IAudioEndpointVolume_GetMasterVolumeLevelScalar(IAudioEndpointVolume* volume, float* level) {
+	return volume->GetMasterVolumeLevelScalar(level);
+}
+
+HRESULT IAudioEndpointVolume_SetMasterVolumeLevelScalar(IAudioEndpointVolume* volume, float level, LPCGUID context) {
+	return volume->SetMasterVolumeLevelScalar(level, context);
+}
+
 void IAudioEndpointVolume_Release(IAudioEndpointVolume* volume) {
 	volume->Release();
 }
+
+//
+// IAudioMeterInformation
+//
+
+HRESULT IMMDevice_ActivateMeter(IMMDevice* device, IAudioMeterInformation** meter) {
+	return device->Activate(__uuidof(IAudioMeterInformation), CLSCTX_ALL, NULL, (void**)meter);
+}
+
+HRESULT IAudioMeterInformation_GetPeakValue(IAudioMeterInformation* meter, float* peak) {
+	return meter->GetPeakValue(peak);
+}
+
+void IAudioMeterInformation_Release(IAudioMeterInformation* meter) {
+	meter->Release();
+}
+
+//
+// IAudioClient
+//
+
+// The peak meter of a capture endpoint reports only while a stream is open on it,
+// so a shared stream is started just to keep it running: its data is never read
+HRESULT IMMDevice_StartCapture(IMMDevice* device, IAudioClient** client) {
+	HRESULT hr = device->Activate(__uuidof(IAudioClient), CLSCTX_ALL, NULL, (void**)client);
+	if (FAILED(hr)) {
+		return hr;
+	}
+
+	WAVEFORMATEX* format;
+	hr = (*client)->GetMixFormat(&format);
+	if (FAILED(hr)) {
+		(*client)->Release();
+		*client = NULL;
+		return hr;
+	}
+
+	// 100ms buffer, expressed in 100ns units
+	hr = (*client)->Initialize(AUDCLNT_SHAREMODE_SHARED, 0, 1000000, 0, format, NULL);
+	CoTaskMemFree(format);
+	if (SUCCEEDED(hr)) {
+		hr = (*client)->Start();
+	}
+
+	if (FAILED(hr)) {
+		(*client)->Release();
+		*client = NULL;
+	}
+	return hr;
+}
+
+void IAudioClient_StopCapture(IAudioClient* client) {
+	client->Stop();
+	client->Release();
+}
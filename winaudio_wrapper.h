@@ -7,6 +7,7 @@
 #include <mmdeviceapi.h>
 #include <functiondiscoverykeys_devpkey.h>
 #include <endpointvolume.h>
+#include <audioclient.h>
 #include <combaseapi.h>
 #include <stdio.h>
 
@@ -16,7 +17,9 @@ extern "C" {
 
 	HRESULT CreateInstance(IMMDeviceEnumerator** deviceEnum);
 
-	HRESULT IMMDeviceEnumerator_EnumAudioEndpoints(IMMDeviceEnumerator* deviceEnum, IMMDeviceCollection** collection);
+	HRESULT IMMDeviceEnumerator_EnumAudioEndpoints(IMMDeviceEnumerator* deviceEnum, EDataFlow flow, DWORD stateMask, IMMDeviceCollection** collection);
+	HRESULT IMMDeviceEnumerator_GetDevice(IMMDeviceEnumerator* deviceEnum, LPCWSTR id, IMMDevice** device);
+	HRESULT IMMDeviceEnumerator_GetDefaultAudioEndpoint(IMMDeviceEnumerator* deviceEnum, EDataFlow flow, ERole role, IMMDevice** device);
 	void IMMDeviceEnumerator_Release(IMMDeviceEnumerator* deviceEnum);
 
 	HRESULT IMMDeviceCollection_GetCount(IMMDeviceCollection* collection, UINT* count);
@@ -26,17 +29,30 @@ extern "C" {
 	HRESULT IMMDevice_GetId(IMMDevice* device, LPWSTR* id);
 	HRESULT IMMDevice_OpenPropertyStore(IMMDevice* device, IPropertyStore** store);
 	HRESULT IMMDevice_Activate(IMMDevice* device, const IID* iid, DWORD dwClsCtx, PROPVARIANT* pActivationParams, void** ppInterface);
+	HRESULT IMMDevice_GetDataFlow(IMMDevice* device, EDataFlow* flow);
+	HRESULT IMMDevice_GetState(IMMDevice* device, DWORD* state);
 	void IMMDevice_Release(IMMDevice* device);
 
 	HRESULT IPropertyStore_GetValue(IPropertyStore* store, const PROPERTYKEY* key, PROPVARIANT* prop);
 	void IPropertyStore_Release(IPropertyStore* store);
 
 	LPWSTR PROPVARIANT_GetStringValue(PROPVARIANT* prop);
+	BOOL PROPVARIANT_GetUIntValue(PROPVARIANT* prop, UINT* value);
+	BOOL PROPVARIANT_GetGUIDValue(PROPVARIANT* prop, GUID* value);
 
 	HRESULT IAudioEndpointVolume_GetMute(IAudioEndpointVolume* volume, BOOL* muted);
 	HRESULT IAudioEndpointVolume_SetMute(IAudioEndpointVolume* volume, BOOL muted, LPCGUID context);
+	HRESULT IAudioEndpointVolume_GetMasterVolumeLevelScalar(IAudioEndpointVolume* volume, float* level);
+	HRESULT IAudioEndpointVolume_SetMasterVolumeLevelScalar(IAudioEndpointVolume* volume, float level, LPCGUID context);
 	void IAudioEndpointVolume_Release(IAudioEndpointVolume* volume);
 
+	HRESULT IMMDevice_ActivateMeter(IMMDevice* device, IAudioMeterInformation** meter);
+	HRESULT IAudioMeterInformation_GetPeakValue(IAudioMeterInformation* meter, float* peak);
+	void IAudioMeterInformation_Release(IAudioMeterInformation* meter);
+
+	HRESULT IMMDevice_StartCapture(IMMDevice* device, IAudioClient** client);
+	void IAudioClient_StopCapture(IAudioClient* client);
+
 #ifdef __cplusplus
 }
 #endif // __cplusplus
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"sync/atomic"
 
 	"github.com/nixpare/broadcaster"
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -15,6 +17,11 @@ import (
 )
 
 type WindowState struct {
+	// Placed is false until the window has been moved or resized at least once
+	Placed bool
+	// Monitor is the ID of the screen the window was on
+	Monitor string
+	// X and Y are relative to the work area of the monitor
 	X, Y int
 	Width, Height int
 }
@@ -35,14 +42,28 @@ type WindowService struct {
 	hotkeyBr *broadcaster.Broadcaster[chan <-error]
 	hotkeyRegistered bool
 
-	WindowState  WindowState  `json:"window"`
-	OverlayState WindowState  `json:"overlay"`
-	HotkeyConfig HotkeyConfig `json:"hotkey"`
+	screensStop chan struct{}
+
+	// overlayMetering is set while the overlay is subscribed to the selected level
+	overlayMetering atomic.Bool
+	// dashboardMetering is set while the dashboard is subscribed to every level
+	dashboardMetering atomic.Bool
+	// windowResizeOff removes the resize listener of the current dashboard
+	windowResizeOff func()
+	// overlayListeners are the event listeners of the current overlay,
+	// removed when it is closed so that a new one does not add more
+	overlayListeners []func()
+
+	WindowState   WindowState   `json:"window"`
+	OverlayState  OverlayState  `json:"overlay"`
+	HotkeyConfig  HotkeyConfig  `json:"hotkey"`
+	StartupConfig StartupConfig `json:"startup"`
 }
 
 func newWindowService() (*WindowService, error) {
 	w := &WindowService{
-		hotkeyBr: broadcaster.NewBroadcaster[chan <-error](),
+		hotkeyBr:    broadcaster.NewBroadcaster[chan <-error](),
+		screensStop: make(chan struct{}),
 	}
 
 	err := w.loadSaveData()
@@ -71,6 +92,7 @@ func (w *WindowService) loadSaveData() error {
 	}
 
 	if len(saveData) == 0 {
+		w.OverlayState.normalize()
 		return nil
 	}
 
@@ -79,10 +101,13 @@ func (w *WindowService) loadSaveData() error {
 		return fmt.Errorf("save data decode: %w", err)
 	}
 
+	w.OverlayState.normalize()
 	return nil
 }
 
 func (w *WindowService) Close() error {
+	close(w.screensStop)
+
 	err := w.updateSaveData()
 	if err != nil {
 		return err
@@ -134,21 +159,50 @@ func (w *WindowService) CreateWindow() {
 	})
 
 	w.window.OnWindowEvent(events.Windows.WindowClose, func(event *application.WindowEvent) {
+		if w.windowResizeOff != nil {
+			w.windowResizeOff()
+			w.windowResizeOff = nil
+		}
+
 		w.window = nil
+		// The page may be gone without telling it stopped watching
+		w.SetDashboardMetering(false)
 	})
 
-	app.OnEvent("window-resize", func(event *application.CustomEvent) {
+	w.windowResizeOff = app.OnEvent("window-resize", func(event *application.CustomEvent) {
 		w.WindowState.Width, w.WindowState.Height = w.window.Size()
 	})
 }
 
+// SetDashboardMetering is called by the dashboard when it becomes visible
+// or hidden. The subscription is kept here, so that closing the window
+// always releases it
+func (w *WindowService) SetDashboardMetering(enabled bool) error {
+	if !w.dashboardMetering.CompareAndSwap(!enabled, enabled) {
+		return nil
+	}
+
+	var err error
+	if enabled {
+		err = audioService.SubscribeLevels()
+	} else {
+		err = audioService.UnsubscribeLevels()
+	}
+
+	if err != nil && enabled {
+		w.dashboardMetering.Store(false)
+	}
+	return err
+}
+
 func (w *WindowService) CreateOverlay() {
 	if w.overlay != nil {
 		w.overlay.Show()
+		w.setOverlayMetering(true)
 		return
 	}
 
-	w.overlay = createWindowOptions(&w.OverlayState, application.WebviewWindowOptions{
+	w.overlay = createWindowOptions(&w.OverlayState.WindowState, application.WebviewWindowOptions{
 		Title:            "AudioSwitch Overlay",
 		URL:              "/overlay.html",
 
@@ -160,29 +214,57 @@ func (w *WindowService) CreateOverlay() {
 		},
 
 		DefaultContextMenuDisabled: true,
+		IgnoreMouseEvents:          w.OverlayState.ClickThrough,
 	})
 
 	w.overlay.OnWindowEvent(events.Windows.WindowClose, func(event *application.WindowEvent) {
+		for _, off := range w.overlayListeners {
+			off()
+		}
+		w.overlayListeners = nil
+
 		w.overlay = nil
+		w.setOverlayMetering(false)
 	})
 
-	app.OnEvent("overlay-resize", func(event *application.CustomEvent) {
-		w.OverlayState.Width, w.OverlayState.Height = w.overlay.Size()
-	})
+	w.overlayListeners = append(w.overlayListeners,
+		app.OnEvent("overlay-resize", func(event *application.CustomEvent) {
+			w.OverlayState.Width, w.OverlayState.Height = w.overlay.Size()
+			// The size changes with the content, the anchor corner must not
+			w.placeOverlay()
+		}),
+		bus.On("audio-device-update", func(data ...any) {
+			if len(data) > 0 {
+				if state, ok := data[0].(State); ok {
+					w.updateOverlayVisibility(state.Muted)
+				}
+			}
+		}),
+	)
+
+	w.applyOverlayConfig()
 }
 
 func (w *WindowService) Exit() {
-	w.window.Close()
-	w.overlay.Close()
+	// The dashboard is not created when starting minimised
+	if w.window != nil {
+		w.window.Close()
+	}
+	if w.overlay != nil {
+		w.overlay.Close()
+	}
 }
 
 func createWindowOptions(state *WindowState, options application.WebviewWindowOptions) *application.WebviewWindow {
-	if state.X == 0 && state.Y == 0 {
-		options.Centered = true
-		options.X, options.Y = 0, 0
-	} else {
+	options.Centered = true
+	options.X, options.Y = 0, 0
+
+	screens, err := app.GetScreens()
+	if err != nil {
+		slog.Warn("screen layout read failed", "err", err)
+	} else if x, y, ok := state.absolutePosition(screens); ok {
 		options.Centered = false
-		options.X, options.Y = state.X, state.Y
+		options.X, options.Y = x, y
 	}
 
 	if state.Width != 0 && state.Height != 0 {
@@ -198,17 +280,51 @@ func createWindowOptions(state *WindowState, options application.WebviewWindowOp
 	})
 	window.OnWindowEvent(events.Common.WindowDidResize, func(event *application.WindowEvent) {
 		updateWindowState(window, state)
-		println("resize")
+		slog.Debug("window resized", "title", options.Title, "width", state.Width, "height", state.Height)
+	})
+	window.OnWindowEvent(events.Common.WindowDPIChanged, func(event *application.WindowEvent) {
+		screens, err := app.GetScreens()
+		if err == nil {
+			validateWindowPosition(window, state, screens)
+		}
 	})
 
 	return window
 }
 
 func updateWindowState(window *application.WebviewWindow, state *WindowState) {
-	state.X, state.Y = window.Position()
+	screen, err := window.GetScreen()
+	if err != nil {
+		slog.Debug("window screen read failed", "err", err)
+		return
+	}
+
+	x, y := window.Position()
+	state.Placed = true
+	state.Monitor = screen.ID
+	state.X, state.Y = x-screen.WorkArea.X, y-screen.WorkArea.Y
 	state.Width, state.Height = window.Width(), window.Height()
 }
 
+func (hk HotkeyConfig) String() string {
+	var keys []string
+	if hk.Ctrl {
+		keys = append(keys, "Ctrl")
+	}
+	if hk.Shift {
+		keys = append(keys, "Shift")
+	}
+	if hk.Alt {
+		keys = append(keys, "Alt")
+	}
+	if hk.Meta {
+		keys = append(keys, "Meta")
+	}
+	keys = append(keys, hk.Key)
+
+	return strings.Join(keys, "+")
+}
+
 func (w *WindowService) GetHotkeyConfig() HotkeyConfig {
 	return w.HotkeyConfig
 }
@@ -234,7 +350,7 @@ func (w *WindowService) RegisterHotkey(data any) error {
 		return nil
 	}
 
-	log.Printf("New hotkey registered: %+v\n", w.HotkeyConfig)
+	slog.Info("hotkey registered", "hotkey", w.HotkeyConfig.String())
 
 	var modifiers []hotkey.Modifier
 	if w.HotkeyConfig.Shift {
@@ -267,9 +383,9 @@ func (w *WindowService) RegisterHotkey(data any) error {
 		for {
 			select {
 			case <-hk.Keydown():
-				err := audioService.ToggleSelected()
+				err := audioService.hotkeyToggle(w.HotkeyConfig.String())
 				if err != nil {
-					log.Printf("toggle selected error: %v\n", err)
+					slog.Error("hotkey toggle failed", "op", "hotkeyToggle", "err", err)
 				}
 			case resultCh = <-listener.Ch():
 				break loop
@@ -3,3 +3,7 @@
 void freeUTF16String(LPWSTR str) {
 	CoTaskMemFree(str);
 }
+
+void playNotificationSound(void) {
+	MessageBeep(MB_ICONWARNING);
+}
@@ -8,6 +8,7 @@ extern "C" {
 #endif
 
 	void freeUTF16String(LPWSTR str);
+	void playNotificationSound(void);
 
 #ifdef __cplusplus
 }